@@ -0,0 +1,254 @@
+package cli
+
+/**
+This package contains the headless mode of ScAr. It drives the same providers as the tui but without a terminal ui.
+So it can be used from cron, over ssh or in scripts.
+*/
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"scar/provider"
+	"scar/util"
+	"strings"
+	"sync"
+)
+
+const (
+	ExitOk            = 0
+	ExitFailed        = 1
+	ExitUsage         = 2
+	ExitLoginFailed   = 3
+	ExitPartialFailed = 4
+)
+
+const usage = `Usage:
+  scar                                       start the terminal ui
+  scar <provider> list [--json]              list all items of a provider
+  scar <provider> sync [--item ID...] [--json]
+                                             download all or only the given items
+  scar <provider> due [--item ID...] [--json]
+                                             list the due dates of the downloaded items
+  scar html build [--provider NAME...]       create the html pages
+
+Providers:
+%s
+Aliases:
+  download can be used instead of sync, --course and --book instead of --item
+`
+
+// stringList is a flag which can be given multiple times
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Run executes the command given in args (without the program name) and returns the exit code
+func Run(args []string, providers []provider.Provider) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(os.Stdout, providers)
+		return ExitOk
+	}
+	if args[0] == "html" {
+		if len(args) < 2 || args[1] != "build" {
+			printUsage(os.Stderr, providers)
+			return ExitUsage
+		}
+		return buildHtml(args[2:], providers)
+	}
+	p := findProvider(args[0], providers)
+	if p == nil || len(args) < 2 {
+		printUsage(os.Stderr, providers)
+		return ExitUsage
+	}
+	switch args[1] {
+	case "list":
+		return listItems(p, args[2:])
+	case "sync", "download":
+		return syncItems(p, args[2:])
+	case "due":
+		return listDueDates(p, args[2:])
+	}
+	printUsage(os.Stderr, providers)
+	return ExitUsage
+}
+
+func printUsage(w io.Writer, providers []provider.Provider) {
+	var names string
+	for _, p := range providers {
+		names += fmt.Sprintf("  %-10s %s\n", p.FolderName(), p.Name())
+	}
+	fmt.Fprintf(w, usage, names)
+}
+
+func findProvider(name string, providers []provider.Provider) provider.Provider {
+	for _, p := range providers {
+		if strings.EqualFold(p.FolderName(), name) || strings.EqualFold(p.Name(), name) {
+			return p
+		}
+	}
+	return nil
+}
+
+func listItems(p provider.Provider, args []string) int {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "print the items as json")
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	out := newOutput(os.Stdout, *jsonOutput)
+	if err := p.Login(); err != nil {
+		out.error("Could not login to "+p.Name(), err)
+		return ExitLoginFailed
+	}
+	defer p.Logout()
+	items, err := p.ListItems()
+	if err != nil {
+		out.error("Could not list items", err)
+		return ExitFailed
+	}
+	out.items(items)
+	return ExitOk
+}
+
+func syncItems(p provider.Provider, args []string) int {
+	var ids stringList
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	flags.Var(&ids, "item", "id of an item to download. Can be given multiple times")
+	flags.Var(&ids, "course", "alias for --item")
+	flags.Var(&ids, "book", "alias for --item")
+	jsonOutput := flags.Bool("json", false, "print the progress as json lines")
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	out := newOutput(os.Stdout, *jsonOutput)
+	if err := p.Login(); err != nil {
+		out.error("Could not login to "+p.Name(), err)
+		return ExitLoginFailed
+	}
+	defer p.Logout()
+	items, err := p.ListItems()
+	if err != nil {
+		out.error("Could not list items", err)
+		return ExitFailed
+	}
+	items, err = selectItems(items, ids)
+	if err != nil {
+		out.error("Invalid item", err)
+		return ExitUsage
+	}
+
+	failed := 0
+	var mu sync.Mutex
+	_ = util.RunParallel(provider.ParallelItems(p), len(items), func(index int) error {
+		item := items[index]
+		out.itemStarted(item, index+1, len(items))
+		err := p.DownloadItem(item, out.reporter(item))
+		if err != nil {
+			mu.Lock()
+			failed++
+			mu.Unlock()
+			out.itemFailed(item, err)
+			return err
+		}
+		out.itemFinished(item)
+		return nil
+	})
+	out.finished(len(items), failed)
+	if failed == 0 {
+		return ExitOk
+	}
+	if failed == len(items) {
+		return ExitFailed
+	}
+	return ExitPartialFailed
+}
+
+func listDueDates(p provider.Provider, args []string) int {
+	var ids stringList
+	flags := flag.NewFlagSet("due", flag.ContinueOnError)
+	flags.Var(&ids, "item", "id of an item to list the due dates of. Can be given multiple times")
+	flags.Var(&ids, "course", "alias for --item")
+	jsonOutput := flags.Bool("json", false, "print the due dates as json")
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	out := newOutput(os.Stdout, *jsonOutput)
+	lister, ok := p.(provider.DueDateLister)
+	if !ok {
+		out.error("Due dates are not supported", fmt.Errorf("%s has no due dates", p.Name()))
+		return ExitUsage
+	}
+	dueDates, err := lister.DueDates(ids)
+	if err != nil {
+		out.error("Could not read due dates", err)
+		return ExitFailed
+	}
+	out.dueDates(dueDates)
+	return ExitOk
+}
+
+// selectItems returns the items with the given ids in the order of the ids. If no ids are given all items are returned
+func selectItems(items []provider.Item, ids []string) ([]provider.Item, error) {
+	if len(ids) == 0 {
+		return items, nil
+	}
+	var selected []provider.Item
+	for _, id := range ids {
+		found := false
+		for _, item := range items {
+			if item.ID == id {
+				selected = append(selected, item)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no item with id %s", id)
+		}
+	}
+	return selected, nil
+}
+
+func buildHtml(args []string, providers []provider.Provider) int {
+	var names stringList
+	flags := flag.NewFlagSet("html build", flag.ContinueOnError)
+	flags.Var(&names, "provider", "provider to create the html for. Can be given multiple times")
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	selected := providers
+	if len(names) != 0 {
+		selected = nil
+		for _, name := range names {
+			p := findProvider(name, providers)
+			if p == nil {
+				fmt.Fprintln(os.Stderr, "Unknown provider:", name)
+				return ExitUsage
+			}
+			selected = append(selected, p)
+		}
+	}
+
+	if err := provider.CreateIndexHtml(providers); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not create index html:", err)
+		return ExitFailed
+	}
+	exitCode := ExitOk
+	for _, p := range selected {
+		if err := p.CreateHtml(); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not create html for %s: %s\n", p.Name(), err)
+			exitCode = ExitFailed
+			continue
+		}
+		fmt.Println("Created html for", p.Name())
+	}
+	return exitCode
+}
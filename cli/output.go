@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"scar/progress"
+	"scar/provider"
+	"sync"
+	"time"
+)
+
+// event is one line of the json output
+type event struct {
+	Event   string          `json:"event"`
+	Item    string          `json:"item,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Index   int             `json:"index,omitempty"`
+	Part    string          `json:"part,omitempty"`
+	Done    int             `json:"done,omitempty"`
+	Total   int             `json:"total,omitempty"`
+	Failed  int             `json:"failed,omitempty"`
+	Bytes   int64           `json:"bytes,omitempty"`
+	Message string          `json:"message,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Items   []provider.Item `json:"items,omitempty"`
+}
+
+// output prints the progress either as plain text or as one json object per line
+type output struct {
+	w    io.Writer
+	json bool
+	mu   sync.Mutex
+}
+
+func newOutput(w io.Writer, json bool) *output {
+	return &output{w: w, json: json}
+}
+
+func (o *output) write(e event, text string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.json {
+		data, _ := json.Marshal(e)
+		fmt.Fprintln(o.w, string(data))
+		return
+	}
+	fmt.Fprintln(o.w, text)
+}
+
+func (o *output) items(items []provider.Item) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.json {
+		data, _ := json.Marshal(items)
+		fmt.Fprintln(o.w, string(data))
+		return
+	}
+	for _, item := range items {
+		fmt.Fprintf(o.w, "%-10s %-30s %s\n", item.ID, item.Name, item.Description)
+	}
+}
+
+func (o *output) dueDates(dueDates []provider.DueDate) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.json {
+		data, _ := json.Marshal(dueDates)
+		fmt.Fprintln(o.w, string(data))
+		return
+	}
+	for _, dueDate := range dueDates {
+		date := "no due date     "
+		if dueDate.DueDate != 0 {
+			date = time.Unix(dueDate.DueDate, 0).Format("02.01.2006 15:04")
+		}
+		fmt.Fprintf(o.w, "%s  %-12s %-20s %s\n", date, dueDate.Status, dueDate.ItemName, dueDate.Name)
+	}
+}
+
+func (o *output) error(message string, err error) {
+	o.write(event{Event: "error", Message: message, Error: err.Error()}, fmt.Sprintf("%s: %s", message, err))
+}
+
+func (o *output) itemStarted(item provider.Item, index int, total int) {
+	o.write(event{Event: "item_started", Item: item.ID, Name: item.Name, Index: index, Total: total},
+		fmt.Sprintf("[%d/%d] Download %s (%s)", index, total, item.Name, item.ID))
+}
+
+func (o *output) itemFinished(item provider.Item) {
+	o.write(event{Event: "item_finished", Item: item.ID, Name: item.Name}, "  Finished "+item.Name)
+}
+
+func (o *output) itemFailed(item provider.Item, err error) {
+	o.write(event{Event: "item_failed", Item: item.ID, Name: item.Name, Error: err.Error()},
+		fmt.Sprintf("  Failed %s: %s", item.Name, err))
+}
+
+func (o *output) finished(total int, failed int) {
+	o.write(event{Event: "finished", Total: total, Failed: failed},
+		fmt.Sprintf("Downloaded %d of %d items", total-failed, total))
+}
+
+// reporter returns the progress reporter for the download of one item
+func (o *output) reporter(item provider.Item) progress.Reporter {
+	return &itemReporter{output: o, item: item}
+}
+
+// itemReporter prints the progress events of one item
+type itemReporter struct {
+	output *output
+	item   provider.Item
+	mu     sync.Mutex
+	total  int
+	done   int
+	bytes  int64
+}
+
+func (r *itemReporter) Started(name string, total int) {
+	r.mu.Lock()
+	r.total = total
+	r.done = 0
+	r.mu.Unlock()
+	r.output.write(event{Event: "started", Item: r.item.ID, Part: name, Total: total}, fmt.Sprintf("  Start %s", name))
+}
+
+func (r *itemReporter) ItemDone(name string) {
+	r.mu.Lock()
+	r.done++
+	e := event{Event: "progress", Item: r.item.ID, Part: name, Done: r.done, Total: r.total, Bytes: r.bytes}
+	r.mu.Unlock()
+	r.output.write(e, "  "+progressText(e.Done, e.Total)+" "+name)
+}
+
+func (r *itemReporter) BytesTransferred(bytes int64) {
+	r.mu.Lock()
+	r.bytes += bytes
+	r.mu.Unlock()
+}
+
+func (r *itemReporter) Info(message string) {
+	r.output.write(event{Event: "info", Item: r.item.ID, Message: message}, "  "+message)
+}
+
+func (r *itemReporter) Warning(message string) {
+	r.output.write(event{Event: "warning", Item: r.item.ID, Message: message}, "  Warning: "+message)
+}
+
+func (r *itemReporter) Failed(name string, err error) {
+	r.mu.Lock()
+	r.done++
+	e := event{Event: "part_failed", Item: r.item.ID, Part: name, Done: r.done, Total: r.total, Error: err.Error()}
+	r.mu.Unlock()
+	r.output.write(e, fmt.Sprintf("  %s Failed %s: %s", progressText(e.Done, e.Total), name, err))
+}
+
+func (r *itemReporter) Finished(name string) {
+	r.mu.Lock()
+	e := event{Event: "completed", Item: r.item.ID, Part: name, Done: r.done, Total: r.total, Bytes: r.bytes}
+	r.mu.Unlock()
+	r.output.write(e, fmt.Sprintf("  Finished %s (%d bytes downloaded)", name, e.Bytes))
+}
+
+func progressText(done int, total int) string {
+	if total <= 0 {
+		return fmt.Sprintf("[%d]", done)
+	}
+	return fmt.Sprintf("[%d/%d]", done, total)
+}
@@ -2,12 +2,11 @@ package digi4school
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
-	"github.com/rivo/tview"
 	"github.com/sirupsen/logrus"
 	"io"
-	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -16,6 +15,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"scar/digi4school/downloader"
+	"scar/httpclient"
+	"scar/progress"
 	"strings"
 	"sync"
 
@@ -25,7 +26,9 @@ import (
 type Digi4SchoolClient struct {
 	Username string
 	Password string
-	Client   *http.Client
+	Client   *httpclient.Client
+	// downloadClient downloads the pages. It sends its own cookies and shares the rate limit with Client
+	downloadClient *httpclient.Client
 }
 
 type BookCookies struct {
@@ -42,19 +45,17 @@ type Book struct {
 	DataId   string
 }
 
-func NewDigi4SClient(username, password string) *Digi4SchoolClient {
-	transport := http.DefaultTransport
+func NewDigi4SClient(username, password string, options httpclient.Options) *Digi4SchoolClient {
 	jar, _ := cookiejar.New(nil)
+	options.Limiter = httpclient.NewHostLimiter(options.RequestsPerSecond)
+	clientOptions := options
+	clientOptions.Jar = jar
+	clientOptions.NoRedirects = true
 	return &Digi4SchoolClient{
-		Username: username,
-		Password: password,
-		Client: &http.Client{
-			Jar: jar,
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-			Transport: transport,
-		},
+		Username:       username,
+		Password:       password,
+		Client:         httpclient.New(clientOptions),
+		downloadClient: httpclient.New(options),
 	}
 }
 
@@ -66,7 +67,6 @@ func (c *Digi4SchoolClient) Login() error {
 	payload.Set("password", c.Password)
 
 	headers := map[string]string{
-		"User-Agent":       "Mozilla/5.0 (Windows NT 10.0; rv:129.0) Gecko/20100101 Firefox/129.0",
 		"Accept":           "text/plain, */*; q=0.01",
 		"Accept-Language":  "en-US,en;q=0.5",
 		"Referer":          "https://digi4school.at/",
@@ -90,7 +90,7 @@ func (c *Digi4SchoolClient) Login() error {
 	}
 	defer resp.Body.Close()
 
-	for _, cookie := range c.Client.Jar.Cookies(req.URL) {
+	for _, cookie := range c.Client.Jar().Cookies(req.URL) {
 		if cookie.Name == "digi4s" {
 			return nil
 		}
@@ -102,7 +102,6 @@ func (c *Digi4SchoolClient) Logout() error {
 	baseUrl := "https://digi4school.at/br/logout"
 
 	headers := map[string]string{
-		"User-Agent":      "Mozilla/5.0 (Windows NT 10.0; rv:129.0) Gecko/20100101 Firefox/129.0",
 		"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/png,image/svg+xml,*/*;q=0.8",
 		"Accept-Language": "en-US,en;q=0.5",
 		"Referer":         "https://digi4school.at/",
@@ -130,10 +129,9 @@ func (c *Digi4SchoolClient) GetBooks() ([]Book, error) {
 	baseUrl := "https://digi4school.at/ebooks"
 	req, err := http.NewRequest("GET", baseUrl, nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; rv:129.0) Gecko/20100101 Firefox/129.0")
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/png,image/svg+xml,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -141,12 +139,12 @@ func (c *Digi4SchoolClient) GetBooks() ([]Book, error) {
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	books := make([]Book, 0)
@@ -164,25 +162,15 @@ func (c *Digi4SchoolClient) GetBooks() ([]Book, error) {
 	return books, nil
 }
 
-func (c *Digi4SchoolClient) DownloadBook(book *Book, filePath string, pageChan chan<- int, view *tview.TextView) error {
+func (c *Digi4SchoolClient) DownloadBook(book *Book, filePath string, reporter progress.Reporter) error {
 	bookCookies, err := c.getBookCookie(book.DataId)
 	if err != nil {
-		logrus.Fatal("Could not get bookCookies: ", err)
+		return fmt.Errorf("could not get book cookies: %w", err)
 	}
 	// create temp dir
 	tmp, err := os.MkdirTemp(os.TempDir(), "bookdl_*")
 	if err != nil {
-		log.Panicln(err)
-	}
-	// save current dir
-	current, err := os.Getwd()
-	if err != nil {
-		log.Panicln(err)
-	}
-	// change into temp dir
-	os.Chdir(tmp)
-	if err != nil {
-		log.Panicln(err)
+		return err
 	}
 
 	digi4bCookie := &http.Cookie{Name: bookCookies.Digi4Bname, Value: bookCookies.Digi4Bvalue}
@@ -192,10 +180,10 @@ func (c *Digi4SchoolClient) DownloadBook(book *Book, filePath string, pageChan c
 	digi4sCookie := &http.Cookie{Name: "digi4s", Value: c.getCurrentDigi4sCookie()}
 
 	downloader.Cookies = append(downloader.Cookies, digi4pCookie, digi4bCookie, digi4sCookie)
-	defer os.Chdir(current)
 	//defer os.RemoveAll(tmp) //TODO: activate again but currently the output.pdf then also gets deleted
 	page := 1
-	view.SetText(view.GetText(false) + "\nDownload Book: " + book.Name)
+	reporter.Started(book.Name, 0)
+	reporter.Info("Download Book: " + book.Name)
 	jobs := make(chan string, 1000)
 	results := make(chan string, 1000)
 	var wg sync.WaitGroup
@@ -210,36 +198,50 @@ func (c *Digi4SchoolClient) DownloadBook(book *Book, filePath string, pageChan c
 		} else {
 			baseUrl = fmt.Sprintf("https://a.digi4school.at/ebook/%s", book.DataCode)
 		}
-		name, err := downloader.DownloadOnePage(fmt.Sprintf("%s/%d.svg", baseUrl, page))
+		name, err := downloader.DownloadOnePage(c.downloadClient, fmt.Sprintf("%s/%d.svg", baseUrl, page), tmp)
 		if name != "" {
 			jobs <- name
+			if fileInfo, err := os.Stat(filepath.Join(tmp, name)); err == nil {
+				reporter.BytesTransferred(fileInfo.Size())
+			}
 		}
-		if err != nil {
-			fmt.Println(err)
+		if errors.Is(err, downloader.ErrPageNotFound) {
+			// the page after the last one does not exist
+			logrus.Info(err)
 			break
 		}
+		if err != nil {
+			close(jobs)
+			wg.Wait()
+			return fmt.Errorf("could not download page %d: %w", page, err)
+		}
 
+		reporter.ItemDone(fmt.Sprintf("Page %d", page))
 		page++
-		pageChan <- page
 	}
-	view.SetText(view.GetText(false) + "\nFinished Book Download")
-	view.SetText(view.GetText(false) + "\nStart Converting Book")
+	reporter.Info("Finished Book Download")
+	reporter.Info("Start Converting Book")
+	// the workers already convert while downloading. The results are buffered so the progress starts at the already converted pages
+	reporter.Started("Convert "+book.Name, page-1)
 	close(jobs)
-	wg.Wait()
-	close(results)
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 	var outputPDFs []string
 	for outputPDF := range results {
 		outputPDFs = append(outputPDFs, outputPDF)
 		logrus.Info(outputPDF)
+		reporter.ItemDone(filepath.Base(outputPDF))
 	}
 
-	outputFile := fmt.Sprintf("output.pdf")
+	outputFile := filepath.Join(tmp, "output.pdf")
 	err = api.MergeCreateFile(outputPDFs, outputFile, false, nil)
 	if err != nil {
-		logrus.Error("Failed to merge PDFs: ", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to merge PDFs: %w", err)
 	}
-	view.SetText(view.GetText(false) + "\nFinished Converting Book")
+	reporter.Info("Finished Converting Book")
+	reporter.Finished(book.Name)
 	return nil
 }
 
@@ -267,10 +269,11 @@ func (c *Digi4SchoolClient) getBookCookie(buchId string) (BookCookies, error) {
 		return BookCookies{}, fmt.Errorf("could not refresh digi4s cookie: %v", err)
 	}
 
-	oauthMap2, _ := c.lti1Request(oauthMap)
-	finishedCookies, _ := c.lti2Request(oauthMap2)
-	fmt.Println(finishedCookies)
-	return finishedCookies, nil
+	oauthMap2, err := c.lti1Request(oauthMap)
+	if err != nil {
+		return BookCookies{}, err
+	}
+	return c.lti2Request(oauthMap2)
 }
 
 func (c *Digi4SchoolClient) lti1Request(params map[string]string) (map[string]string, error) {
@@ -283,10 +286,9 @@ func (c *Digi4SchoolClient) lti1Request(params map[string]string) (map[string]st
 	encodedFormData := strings.Join(queryParams, "&")
 	req, err := http.NewRequest("POST", baseUrl, bytes.NewBufferString(encodedFormData))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; rv:129.0) Gecko/20100101 Firefox/129.0")
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/png,image/svg+xml,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -295,7 +297,7 @@ func (c *Digi4SchoolClient) lti1Request(params map[string]string) (map[string]st
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	defer resp.Body.Close()
@@ -317,10 +319,9 @@ func (c *Digi4SchoolClient) lti2Request(params map[string]string) (BookCookies,
 	encodedFormData := strings.Join(queryParams, "&")
 	req, err := http.NewRequest("POST", baseUrl, bytes.NewBufferString(encodedFormData))
 	if err != nil {
-		log.Fatal(err)
+		return BookCookies{}, err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; rv:129.0) Gecko/20100101 Firefox/129.0")
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/png,image/svg+xml,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -329,13 +330,16 @@ func (c *Digi4SchoolClient) lti2Request(params map[string]string) (BookCookies,
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
-		log.Fatal(err)
+		return BookCookies{}, err
 	}
 	defer resp.Body.Close()
 
 	finishedCookies := BookCookies{}
 
-	finishedCookies.SubPath = c.checkSubPath(resp.Header.Get("Location"))
+	finishedCookies.SubPath, err = c.checkSubPath(resp.Header.Get("Location"))
+	if err != nil {
+		return BookCookies{}, err
+	}
 
 	for _, cookie := range resp.Cookies() {
 		if cookie.Name == "digi4b" {
@@ -348,17 +352,16 @@ func (c *Digi4SchoolClient) lti2Request(params map[string]string) (BookCookies,
 		}
 	}
 	if finishedCookies.Digi4Bvalue == "" || finishedCookies.Digi4Pvalue == "" {
-		//error handling
+		return BookCookies{}, fmt.Errorf("digi4school did not return the book cookies")
 	}
 	return finishedCookies, nil
 }
-func (c *Digi4SchoolClient) checkSubPath(url string) string {
+func (c *Digi4SchoolClient) checkSubPath(url string) (string, error) {
 	req, err := http.NewRequest("POST", url, nil)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; rv:129.0) Gecko/20100101 Firefox/129.0")
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/png,image/svg+xml,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -367,16 +370,19 @@ func (c *Digi4SchoolClient) checkSubPath(url string) string {
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
 	if !strings.Contains(string(body), "sbnr") {
-		return "1"
+		return "1", nil
 	}
 
-	return ""
+	return "", nil
 }
 
 func (c *Digi4SchoolClient) getOauthMap(buchId string) (map[string]string, error) {
@@ -384,10 +390,9 @@ func (c *Digi4SchoolClient) getOauthMap(buchId string) (map[string]string, error
 
 	req, err := http.NewRequest("GET", baseUrl, nil)
 	if err != nil {
-		log.Fatalf("Failed to create request: %v", err)
+		return map[string]string{}, fmt.Errorf("failed to create request: %v", err)
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; rv:129.0) Gecko/20100101 Firefox/129.0")
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/png,image/svg+xml,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("Priority", "u=0, i")
@@ -399,12 +404,15 @@ func (c *Digi4SchoolClient) getOauthMap(buchId string) (map[string]string, error
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return map[string]string{}, fmt.Errorf("could not load: %v", err)
+	}
 	return extractParams(string(body)), nil
 }
 
 func (c *Digi4SchoolClient) getCurrentDigi4sCookie() string {
 	uri, _ := url.Parse("https://a.digi4school.at")
-	for _, cookie := range c.Client.Jar.Cookies(uri) {
+	for _, cookie := range c.Client.Jar().Cookies(uri) {
 		if cookie.Name == "digi4s" {
 			return cookie.Value
 		}
@@ -1,33 +1,40 @@
 package downloader
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"scar/httpclient"
 	"strings"
 )
 
 var Cookies []*http.Cookie
 
-func downloadEmbeddedAsset(url string, matches [][]string) {
+// ErrPageNotFound is returned by DownloadOnePage after the last page of a book
+var ErrPageNotFound = errors.New("page not found")
+
+// downloadEmbeddedAsset downloads the images of a page into dir
+func downloadEmbeddedAsset(client *httpclient.Client, url string, dir string, matches [][]string) error {
 	trimmedURL := url[:strings.LastIndex(url, "/")+1]
 	for _, match := range matches {
 		if len(match) > 1 {
-			downloadFile(fmt.Sprintf(trimmedURL + match[1]))
+			if err := downloadFile(client, trimmedURL+match[1], dir); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
 
-// function used to download one asset file (ex. embedded images)
-func downloadFile(url string) {
-	client := &http.Client{}
-
+// function used to download one asset file (ex. embedded images) into dir
+func downloadFile(client *httpclient.Client, url string, dir string) error {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Panicln("failed to create request")
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// add all cookies saved in cookies array (generated by CreateCookie function)
@@ -38,37 +45,42 @@ func downloadFile(url string) {
 	// execute request and save response
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Panicln("failed to get file:", err)
+		return fmt.Errorf("failed to get file: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to get file %s: status code %d", url, resp.StatusCode)
+	}
 
-	dirname := GetDirName(url)
+	dirname := filepath.Join(dir, GetDirName(url))
 
 	// create file
 	if _, err := os.Stat(dirname); os.IsNotExist(err) {
-		os.MkdirAll(dirname, 0700) // Create your file
+		if err := os.MkdirAll(dirname, 0700); err != nil {
+			return err
+		}
 	}
 
-	file, err := os.Create(dirname + path.Base(url))
+	file, err := os.Create(filepath.Join(dirname, path.Base(url)))
 	if err != nil {
-		log.Panicln("failed to create file")
+		return fmt.Errorf("failed to create file: %w", err)
 	}
 	defer file.Close()
 
 	// write contens of response to file
 	_, err = io.Copy(file, resp.Body)
 	if err != nil {
-		log.Panicln("failed to copy file content:", err)
+		return fmt.Errorf("failed to copy file content: %w", err)
 	}
-
+	return nil
 }
 
-func DownloadOnePage(url string) (string, error) {
+// DownloadOnePage saves the page and its images in dir and returns the file name of the page inside dir
+func DownloadOnePage(client *httpclient.Client, url string, dir string) (string, error) {
 	// create request
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Panicln("failed to create request")
-		return "", err
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// set all cookies created from createCookie function
@@ -76,26 +88,25 @@ func DownloadOnePage(url string) (string, error) {
 		req.AddCookie(cookie)
 	}
 
-	client := &http.Client{}
-
 	// execute request and save response
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Panicln("failed to get file:", err)
-		return "", err
+		return "", fmt.Errorf("failed to get file: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// check if page exists
 	if resp.StatusCode == http.StatusNotFound {
-		return "", fmt.Errorf("ERR 404 - %s", url)
+		return "", fmt.Errorf("%w: ERR 404 - %s", ErrPageNotFound, url)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("failed to get page %s: status code %d", url, resp.StatusCode)
 	}
 
 	// Convert the body to a string
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		// Handle error
-		log.Panicln("Error:", err)
+		return "", err
 	}
 
 	// Convert the body to a string
@@ -105,7 +116,9 @@ func DownloadOnePage(url string) (string, error) {
 	if strings.Contains(bodyString, "image") {
 		matches := CheckForEmbeddedImages(bodyString)
 		if len(matches) > 0 {
-			downloadEmbeddedAsset(url, matches)
+			if err := downloadEmbeddedAsset(client, url, dir, matches); err != nil {
+				return "", err
+			}
 		}
 	}
 	// set filename
@@ -116,18 +129,16 @@ func DownloadOnePage(url string) (string, error) {
 	filename = number + "." + parts[1]
 
 	// create file
-	file, err := os.Create(filename)
+	file, err := os.Create(filepath.Join(dir, filename))
 	if err != nil {
-		log.Panicln("failed to create file")
-		return "", err
+		return "", fmt.Errorf("failed to create file: %w", err)
 	}
 	defer file.Close()
 
 	// write contens of response to file
 	_, err = io.WriteString(file, bodyString)
 	if err != nil {
-		log.Panicln("failed to copy file content:", err)
-		return "", err
+		return "", fmt.Errorf("failed to copy file content: %w", err)
 	}
 	return filename, nil
 }
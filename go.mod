@@ -8,6 +8,7 @@ require (
 	github.com/pdfcpu/pdfcpu v0.8.1
 	github.com/rivo/tview v0.0.0-20240524063012-037df494fb76
 	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/net v0.24.0
 )
 
 require (
@@ -20,7 +21,6 @@ require (
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/image v0.19.0 // indirect
-	golang.org/x/net v0.24.0 // indirect
 	golang.org/x/sys v0.20.0 // indirect
 	golang.org/x/term v0.19.0 // indirect
 	golang.org/x/text v0.17.0 // indirect
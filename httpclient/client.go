@@ -0,0 +1,235 @@
+package httpclient
+
+/**
+This package contains the http client which every provider uses. It has timeouts, retries failed requests with a
+backoff, limits the requests per host and sends the same User-Agent everywhere.
+*/
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"scar/util"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultUserAgent  = "Mozilla/5.0 (Windows NT 10.0; rv:129.0) Gecko/20100101 Firefox/129.0"
+	DefaultTimeout    = 60 * time.Second
+	DefaultMaxRetries = 3
+	connectTimeout    = 30 * time.Second
+	retryBaseDelay    = time.Second
+	// maxRetryDelay caps the backoff and the Retry-After of a server
+	maxRetryDelay = 2 * time.Minute
+)
+
+type Options struct {
+	// Timeout is how long to wait for the response headers and how long reading the body may stall. Reading the body of
+	// big files can take longer as long as data arrives
+	Timeout time.Duration
+	// MaxRetries is how often a request with an idempotent method is repeated after a network error, a 5xx or a 429
+	// response
+	MaxRetries int
+	// RequestsPerSecond limits the requests to one host. 0 disables the limit
+	RequestsPerSecond float64
+	// Limiter is shared by clients which send requests to the same hosts. If it is nil a new one with RequestsPerSecond
+	// is created
+	Limiter *HostLimiter
+	// UserAgent is set on every request which has no User-Agent yet
+	UserAgent string
+	SkipSSL   bool
+	Jar       http.CookieJar
+	// NoRedirects returns redirect responses to the caller instead of following them
+	NoRedirects bool
+}
+
+// DefaultOptions returns the options which are used if nothing is configured
+func DefaultOptions() Options {
+	return Options{Timeout: DefaultTimeout, MaxRetries: DefaultMaxRetries, UserAgent: DefaultUserAgent}
+}
+
+// ConfigOptions returns the options from the config. The config has to be loaded
+func ConfigOptions() Options {
+	options := DefaultOptions()
+	options.Timeout = time.Duration(util.Config.GetInt("http_timeout_seconds", int(DefaultTimeout.Seconds()))) * time.Second
+	options.MaxRetries = util.Config.GetInt("http_max_retries", DefaultMaxRetries)
+	options.UserAgent = util.Config.GetStringWD("http_user_agent", DefaultUserAgent)
+	return options
+}
+
+type Client struct {
+	HTTP    *http.Client
+	options Options
+	limiter *HostLimiter
+}
+
+func New(options Options) *Client {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: options.SkipSSL},
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: options.Timeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   8,
+	}
+	client := &http.Client{Transport: transport, Jar: options.Jar}
+	if options.NoRedirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	limiter := options.Limiter
+	if limiter == nil {
+		limiter = NewHostLimiter(options.RequestsPerSecond)
+	}
+	return &Client{HTTP: client, options: options, limiter: limiter}
+}
+
+// Jar returns the cookie jar of the client or nil
+func (c *Client) Jar() http.CookieJar {
+	return c.HTTP.Jar
+}
+
+// Do sends the request and retries it after network errors, 5xx and 429 responses. Only requests with an idempotent
+// method are retried, a POST could have been processed already. A request with a body is only retried if the body can
+// be read again (http.NewRequest does this for readers of strings and bytes).
+// After the last retry the response or error of the last attempt is returned
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" && c.options.UserAgent != "" {
+		req.Header.Set("User-Agent", c.options.UserAgent)
+	}
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		c.limiter.Wait(req.URL.Host)
+		ctx, cancel := context.WithCancelCause(req.Context())
+		resp, err := c.HTTP.Do(req.WithContext(ctx))
+		if attempt >= c.options.MaxRetries || !c.shouldRetry(req, resp, err) {
+			if err != nil {
+				cancel(nil)
+				return resp, err
+			}
+			resp.Body = newIdleTimeoutBody(ctx, resp.Body, c.options.Timeout, cancel)
+			return resp, nil
+		}
+		delay := retryDelay(attempt, resp)
+		if err != nil {
+			logrus.Infof("Retry %s %s in %s: %s", req.Method, req.URL.Redacted(), delay, err)
+		} else {
+			logrus.Infof("Retry %s %s in %s: status code %d", req.Method, req.URL.Redacted(), delay, resp.StatusCode)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel(nil)
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+func (c *Client) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if !isIdempotent(req.Method) {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// idleTimeoutBody cancels the request if no data arrives for the timeout while the body is read. Without it a stalled
+// download would block forever because the transport only has a timeout for the response headers
+type idleTimeoutBody struct {
+	ctx    context.Context
+	body   io.ReadCloser
+	timer  *time.Timer
+	cancel context.CancelCauseFunc
+	// timeout 0 disables the timer
+	timeout time.Duration
+}
+
+func newIdleTimeoutBody(ctx context.Context, body io.ReadCloser, timeout time.Duration, cancel context.CancelCauseFunc) *idleTimeoutBody {
+	b := &idleTimeoutBody{ctx: ctx, body: body, cancel: cancel, timeout: timeout}
+	if timeout > 0 {
+		b.timer = time.AfterFunc(timeout, func() {
+			cancel(fmt.Errorf("no data received for %s", timeout))
+		})
+	}
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if err != nil && err != io.EOF && context.Cause(b.ctx) != nil {
+		// report why the request was canceled instead of only "context canceled"
+		err = context.Cause(b.ctx)
+	}
+	if b.timer != nil {
+		if err != nil {
+			b.timer.Stop()
+		} else {
+			b.timer.Reset(b.timeout)
+		}
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	err := b.body.Close()
+	b.cancel(nil)
+	return err
+}
+
+// retryDelay returns the Retry-After of the response or an exponential backoff with some jitter
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(delay, maxRetryDelay)
+		}
+	}
+	// larger shifts are above maxRetryDelay anyway and could overflow
+	delay := retryBaseDelay << min(attempt, 8)
+	delay += time.Duration(rand.Int63n(int64(delay) / 2))
+	return min(delay, maxRetryDelay)
+}
+
+// parseRetryAfter reads a Retry-After header. It is either the seconds to wait or a http date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,38 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+// HostLimiter limits the requests per second to every host. The requests to different hosts do not limit each other
+type HostLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     map[string]time.Time
+}
+
+// NewHostLimiter creates a limiter which allows requestsPerSecond requests to one host. 0 disables the limit
+func NewHostLimiter(requestsPerSecond float64) *HostLimiter {
+	limiter := &HostLimiter{next: map[string]time.Time{}}
+	if requestsPerSecond > 0 {
+		limiter.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return limiter
+}
+
+// Wait blocks until the next request to the host is allowed
+func (hl *HostLimiter) Wait(host string) {
+	if hl == nil || hl.interval == 0 {
+		return
+	}
+	hl.mu.Lock()
+	now := time.Now()
+	slot := hl.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	hl.next[host] = slot.Add(hl.interval)
+	hl.mu.Unlock()
+	time.Sleep(time.Until(slot))
+}
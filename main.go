@@ -4,8 +4,10 @@ import (
 	"github.com/sirupsen/logrus"
 	"log"
 	"os"
+	"scar/cli"
 	"scar/digi4school"
 	"scar/moodle"
+	"scar/provider"
 	"scar/screen"
 	"scar/util"
 )
@@ -20,9 +22,16 @@ func main() {
 	}(file)
 	logrus.SetOutput(file)
 	util.Config.Load()
+	providers := []provider.Provider{moodle.NewMoodleProvider(), digi4school.NewD4SProvider()}
+	if len(os.Args) > 1 {
+		exitCode := cli.Run(os.Args[1:], providers)
+		_ = file.Close()
+		os.Exit(exitCode)
+	}
 	screen.CreateApplication()
-	screen.AddScreen(moodle.GetMoodleScreen())
-	screen.AddScreen(digi4school.GetD4SScreen())
+	for _, p := range providers {
+		screen.AddProvider(p)
+	}
 	screen.RunApplication()
 
 }
@@ -0,0 +1,135 @@
+package moodle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"scar/progress"
+	"scar/util"
+	"strings"
+)
+
+// bookStructureItem is one chapter in the structure content of a book module
+type bookStructureItem struct {
+	Title    string              `json:"title"`
+	Href     string              `json:"href"`
+	Level    int                 `json:"level"`
+	Hidden   moodleFlag          `json:"hidden"`
+	SubItems []bookStructureItem `json:"subitems"`
+}
+
+// moodleFlag accepts a flag as string ("0", "1"), number or bool. Moodle versions differ in how they return flags
+type moodleFlag bool
+
+func (flag *moodleFlag) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case bool:
+		*flag = moodleFlag(value)
+	case float64:
+		*flag = value != 0
+	case string:
+		*flag = value != "" && value != "0" && value != "false"
+	default:
+		*flag = false
+	}
+	return nil
+}
+
+type DownloadBookData struct {
+	ID          int           `json:"id"`
+	CMID        int           `json:"cmid"`
+	Name        string        `json:"name"`
+	ModName     string        `json:"modname"`
+	Description string        `json:"description"`
+	Chapters    []BookChapter `json:"chapters"`
+}
+
+type BookChapter struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Level  int    `json:"level"`
+	Hidden bool   `json:"hidden"`
+	// Path is the downloaded index.html of the chapter relative to the module folder
+	Path string `json:"path"`
+	// Content is the html of the chapter. All links to files of the book are relative to the module folder
+	Content     string        `json:"content"`
+	SubChapters []BookChapter `json:"subchapters"`
+}
+
+func (courseApi *CourseApi) downloadBookModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
+	modulePath := fmt.Sprintf("%s/%d", basePath, moduleFolderID(module))
+
+	var structure []bookStructureItem
+	files := map[string]string{}
+	for _, content := range module.Contents {
+		if content.Type == "content" && content.FileName == "structure" {
+			if err := json.Unmarshal([]byte(content.Content), &structure); err != nil {
+				return fmt.Errorf("could not parse book structure: %w", err)
+			}
+			continue
+		}
+		if content.Type != "file" {
+			continue
+		}
+		relPath := contentRelPath(content)
+		err := courseApi.downloadModuleFile(content.moodleFile(), modulePath, relPath, manifest, reporter)
+		if err != nil {
+			return err
+		}
+		files[fileAreaPath(content.FilePath, content.FileName)] = relPath
+	}
+
+	var data DownloadBookData
+	data.ID = module.ID
+	data.CMID = module.ComponentID
+	data.Name = module.Name
+	data.ModName = module.ModName
+	data.Description = courseApi.localizePluginFiles(module.Description, modulePath, "inline", manifest, reporter)
+	data.Chapters = courseApi.buildBookChapters(structure, modulePath, files, reporter)
+
+	return util.SaveStructToJSON(data, modulePath+"/data.json")
+}
+
+// buildBookChapters reads the downloaded chapters of the structure and replaces their pluginfile links with the local files
+func (courseApi *CourseApi) buildBookChapters(structure []bookStructureItem, modulePath string, files map[string]string, reporter progress.Reporter) []BookChapter {
+	var chapters []BookChapter
+	for _, item := range structure {
+		chapter := BookChapter{
+			ID:     strings.Split(item.Href, "/")[0],
+			Title:  item.Title,
+			Level:  item.Level,
+			Hidden: bool(item.Hidden),
+		}
+		if relPath, ok := files[fileAreaPath("/", item.Href)]; ok {
+			chapter.Path = relPath
+			index, err := os.ReadFile(path.Join(modulePath, relPath))
+			if err != nil {
+				reporter.Warning(fmt.Sprintf("Could not read chapter %s: %s", item.Title, err))
+			} else {
+				content, err := rewriteHtmlLinks(string(index), func(link string) string {
+					if !isPluginFileLink(link) {
+						return link
+					}
+					if localPath, ok := matchPluginFile(link, files); ok {
+						return localLink(localPath)
+					}
+					return link
+				})
+				if err != nil {
+					reporter.Warning(fmt.Sprintf("Could not localize the files of chapter %s: %s", item.Title, err))
+				}
+				chapter.Content = content
+			}
+		} else {
+			reporter.Warning(fmt.Sprintf("Chapter %s was not downloaded", item.Title))
+		}
+		chapter.SubChapters = courseApi.buildBookChapters(item.SubItems, modulePath, files, reporter)
+		chapters = append(chapters, chapter)
+	}
+	return chapters
+}
@@ -0,0 +1,34 @@
+package moodle
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBookStructureHidden(t *testing.T) {
+	tests := []struct {
+		hidden string
+		want   bool
+	}{
+		{`"1"`, true},
+		{`"0"`, false},
+		{`""`, false},
+		{`1`, true},
+		{`0`, false},
+		{`true`, true},
+		{`false`, false},
+		{`null`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.hidden, func(t *testing.T) {
+			var items []bookStructureItem
+			data := `[{"title":"Chapter","href":"1/index.html","level":0,"hidden":` + test.hidden + `,"subitems":[]}]`
+			if err := json.Unmarshal([]byte(data), &items); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if bool(items[0].Hidden) != test.want {
+				t.Errorf("Hidden = %v, want %v", items[0].Hidden, test.want)
+			}
+		})
+	}
+}
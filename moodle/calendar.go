@@ -0,0 +1,343 @@
+package moodle
+
+/**
+Exports the calendar events and deadlines of the courses as iCalendar files. Every course gets a calendar.json with all
+known events and a calendar.ics. The moodle folder gets a calendar.ics with the events of all courses.
+The events are updated incrementally: only events which start after the last sync (minus calendarResyncPeriod) are
+requested again. Older events are kept from the calendar.json.
+*/
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/url"
+	"os"
+	"path/filepath"
+	"scar/util"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	calendarFileName    = "calendar.json"
+	calendarIcsFileName = "calendar.ics"
+	// calendarResyncPeriod is how long before the last sync events are requested again to catch late changes
+	calendarResyncPeriod   = 30 * 24 * time.Hour
+	actionEventsPerRequest = 50
+)
+
+type CalendarEventsResponse struct {
+	Events []struct {
+		ID           int    `json:"id"`
+		Name         string `json:"name"`
+		Description  string `json:"description"`
+		CourseID     int    `json:"courseid"`
+		ModuleName   string `json:"modulename"`
+		Instance     int    `json:"instance"`
+		EventType    string `json:"eventtype"`
+		TimeStart    int64  `json:"timestart"`
+		TimeDuration int64  `json:"timeduration"`
+		TimeModified int64  `json:"timemodified"`
+	} `json:"events"`
+	Error string `json:"error"`
+}
+
+type ActionEventsResponse struct {
+	Events []struct {
+		ID           int    `json:"id"`
+		Name         string `json:"name"`
+		Description  string `json:"description"`
+		ModuleName   string `json:"modulename"`
+		Instance     int    `json:"instance"`
+		EventType    string `json:"eventtype"`
+		TimeStart    int64  `json:"timestart"`
+		TimeDuration int64  `json:"timeduration"`
+		TimeModified int64  `json:"timemodified"`
+		URL          string `json:"url"`
+		Course       struct {
+			ID int `json:"id"`
+		} `json:"course"`
+	} `json:"events"`
+	Error string `json:"error"`
+}
+
+// CalendarEvent is one event of a course. Deadlines like the due date of an assignment are events too
+type CalendarEvent struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	CourseID     int    `json:"courseid"`
+	ModuleName   string `json:"modulename"`
+	Instance     int    `json:"instance"`
+	EventType    string `json:"eventtype"`
+	TimeStart    int64  `json:"timestart"`
+	TimeDuration int64  `json:"timeduration"`
+	TimeModified int64  `json:"timemodified"`
+	URL          string `json:"url"`
+}
+
+// CourseCalendar is the content of the calendar.json of a course
+type CourseCalendar struct {
+	CourseID  int             `json:"courseid"`
+	ShortName string          `json:"shortname"`
+	LastSync  int64           `json:"lastsync"`
+	Events    []CalendarEvent `json:"events"`
+}
+
+func (courseApi *CourseApi) getCalendarEvents(courseID int, timeStart int64) ([]CalendarEvent, error) {
+	body, err := courseApi.client.makeWebserviceRequest("core_calendar_get_calendar_events", map[string]string{
+		"events[courseids][0]": strconv.Itoa(courseID),
+		"options[userevents]":  "0",
+		"options[siteevents]":  "0",
+		"options[timestart]":   strconv.FormatInt(timeStart, 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response CalendarEventsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%v", response.Error)
+	}
+	var events []CalendarEvent
+	for _, event := range response.Events {
+		events = append(events, CalendarEvent{
+			ID:           event.ID,
+			Name:         event.Name,
+			Description:  event.Description,
+			CourseID:     event.CourseID,
+			ModuleName:   event.ModuleName,
+			Instance:     event.Instance,
+			EventType:    event.EventType,
+			TimeStart:    event.TimeStart,
+			TimeDuration: event.TimeDuration,
+			TimeModified: event.TimeModified,
+		})
+	}
+	return events, nil
+}
+
+// getActionEvents returns the deadlines of the course which are shown in the timeline of moodle
+func (courseApi *CourseApi) getActionEvents(courseID int, timeSortFrom int64) ([]CalendarEvent, error) {
+	var events []CalendarEvent
+	afterEventID := 0
+	for {
+		params := map[string]string{
+			"courseid":     strconv.Itoa(courseID),
+			"timesortfrom": strconv.FormatInt(timeSortFrom, 10),
+			"limitnum":     strconv.Itoa(actionEventsPerRequest),
+		}
+		if afterEventID != 0 {
+			params["aftereventid"] = strconv.Itoa(afterEventID)
+		}
+		body, err := courseApi.client.makeWebserviceRequest("core_calendar_get_action_events_by_course", params)
+		if err != nil {
+			return nil, err
+		}
+		var response ActionEventsResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		if response.Error != "" {
+			return nil, fmt.Errorf("%v", response.Error)
+		}
+		for _, event := range response.Events {
+			afterEventID = event.ID
+			events = append(events, CalendarEvent{
+				ID:           event.ID,
+				Name:         event.Name,
+				Description:  event.Description,
+				CourseID:     courseID,
+				ModuleName:   event.ModuleName,
+				Instance:     event.Instance,
+				EventType:    event.EventType,
+				TimeStart:    event.TimeStart,
+				TimeDuration: event.TimeDuration,
+				TimeModified: event.TimeModified,
+				URL:          event.URL,
+			})
+		}
+		if len(response.Events) < actionEventsPerRequest {
+			return events, nil
+		}
+	}
+}
+
+// syncCalendar updates the calendar.json and calendar.ics of the course and the combined calendar.ics of all courses
+func (courseApi *CourseApi) syncCalendar(course *Course, basePath string) error {
+	coursePath := filepath.Join(basePath, strconv.Itoa(course.ID))
+	calendar := loadCourseCalendar(coursePath)
+	calendar.CourseID = course.ID
+	calendar.ShortName = course.ShortName
+
+	var windowStart int64
+	if calendar.LastSync != 0 {
+		windowStart = calendar.LastSync - int64(calendarResyncPeriod.Seconds())
+	}
+	events, err := courseApi.getCalendarEvents(course.ID, windowStart)
+	if err != nil {
+		return err
+	}
+	actionEvents, err := courseApi.getActionEvents(course.ID, windowStart)
+	if err != nil {
+		return err
+	}
+
+	// events inside the window which moodle does not return anymore were deleted
+	merged := map[int]CalendarEvent{}
+	for _, event := range calendar.Events {
+		if event.TimeStart < windowStart {
+			merged[event.ID] = event
+		}
+	}
+	for _, event := range events {
+		merged[event.ID] = event
+	}
+	for _, event := range actionEvents {
+		if existing, ok := merged[event.ID]; ok && event.Description == "" {
+			event.Description = existing.Description
+		}
+		merged[event.ID] = event
+	}
+	calendar.Events = calendar.Events[:0]
+	for _, event := range merged {
+		calendar.Events = append(calendar.Events, event)
+	}
+	sortCalendarEvents(calendar.Events)
+	calendar.LastSync = time.Now().Unix()
+
+	if err := util.SaveStructToJSON(calendar, filepath.Join(coursePath, calendarFileName)); err != nil {
+		return err
+	}
+	host := courseApi.calendarHost()
+	if err := writeIcs(filepath.Join(coursePath, calendarIcsFileName), course.ShortName, map[int]string{course.ID: course.ShortName}, calendar.Events, host); err != nil {
+		return err
+	}
+	return courseApi.writeCombinedCalendar(basePath, host)
+}
+
+// writeCombinedCalendar writes the events of all courses from GetCourses into one calendar.ics
+func (courseApi *CourseApi) writeCombinedCalendar(basePath string, host string) error {
+	courseApi.calendarMu.Lock()
+	defer courseApi.calendarMu.Unlock()
+	var events []CalendarEvent
+	courseNames := map[int]string{}
+	for _, course := range courseApi.courseCache.Courses {
+		courseNames[course.ID] = course.ShortName
+		calendar := loadCourseCalendar(filepath.Join(basePath, strconv.Itoa(course.ID)))
+		events = append(events, calendar.Events...)
+	}
+	sortCalendarEvents(events)
+	return writeIcs(filepath.Join(basePath, calendarIcsFileName), "Moodle", courseNames, events, host)
+}
+
+func loadCourseCalendar(coursePath string) CourseCalendar {
+	var calendar CourseCalendar
+	data, err := os.ReadFile(filepath.Join(coursePath, calendarFileName))
+	if err != nil {
+		return calendar
+	}
+	if err := json.Unmarshal(data, &calendar); err != nil {
+		return CourseCalendar{}
+	}
+	return calendar
+}
+
+func sortCalendarEvents(events []CalendarEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].TimeStart != events[j].TimeStart {
+			return events[i].TimeStart < events[j].TimeStart
+		}
+		return events[i].ID < events[j].ID
+	})
+}
+
+// calendarHost returns the host of the moodle instance which makes the uids of the events unique
+func (courseApi *CourseApi) calendarHost() string {
+	parsed, err := url.Parse(courseApi.client.ServiceUrl)
+	if err != nil || parsed.Host == "" {
+		return "moodle"
+	}
+	return parsed.Host
+}
+
+// writeIcs writes the events as an iCalendar (RFC 5545) file
+func writeIcs(filePath string, name string, courseNames map[int]string, events []CalendarEvent, host string) error {
+	var builder strings.Builder
+	writeIcsLine(&builder, "BEGIN:VCALENDAR")
+	writeIcsLine(&builder, "VERSION:2.0")
+	writeIcsLine(&builder, "PRODID:-//ScAr//Moodle calendar//EN")
+	writeIcsLine(&builder, "CALSCALE:GREGORIAN")
+	writeIcsLine(&builder, "X-WR-CALNAME:"+escapeIcsText(name))
+	// DTSTAMP is when the calendar was exported. The timemodified of an event can be 0
+	exported := icsTime(time.Now().Unix())
+	for _, event := range events {
+		writeIcsLine(&builder, "BEGIN:VEVENT")
+		writeIcsLine(&builder, fmt.Sprintf("UID:moodle-%d@%s", event.ID, host))
+		writeIcsLine(&builder, "DTSTAMP:"+exported)
+		if event.TimeModified != 0 {
+			writeIcsLine(&builder, "LAST-MODIFIED:"+icsTime(event.TimeModified))
+		}
+		writeIcsLine(&builder, "DTSTART:"+icsTime(event.TimeStart))
+		if event.TimeDuration > 0 {
+			writeIcsLine(&builder, "DTEND:"+icsTime(event.TimeStart+event.TimeDuration))
+		}
+		writeIcsLine(&builder, "SUMMARY:"+escapeIcsText(event.Name))
+		if description := htmlToText(event.Description); description != "" {
+			writeIcsLine(&builder, "DESCRIPTION:"+escapeIcsText(description))
+		}
+		if courseName, ok := courseNames[event.CourseID]; ok {
+			writeIcsLine(&builder, "CATEGORIES:"+escapeIcsText(courseName))
+		}
+		if event.URL != "" {
+			writeIcsLine(&builder, "URL:"+event.URL)
+		}
+		writeIcsLine(&builder, "END:VEVENT")
+	}
+	writeIcsLine(&builder, "END:VCALENDAR")
+
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, []byte(builder.String()), 0644)
+}
+
+// writeIcsLine writes a content line and folds it after 75 octets. Folded lines start with a space
+func writeIcsLine(builder *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// do not split utf-8 characters
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		builder.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	builder.WriteString(line + "\r\n")
+}
+
+func escapeIcsText(text string) string {
+	return strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\r\n", "\\n", "\n", "\\n").Replace(text)
+}
+
+func icsTime(timestamp int64) string {
+	return time.Unix(timestamp, 0).UTC().Format("20060102T150405Z")
+}
+
+// htmlToText returns the text of a html description
+func htmlToText(content string) string {
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+	return strings.TrimSpace(doc.Text())
+}
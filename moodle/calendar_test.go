@@ -0,0 +1,94 @@
+package moodle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteIcsLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"short", "SUMMARY:Test", "SUMMARY:Test\r\n"},
+		{"exactly 75", strings.Repeat("a", 75), strings.Repeat("a", 75) + "\r\n"},
+		{"folded", strings.Repeat("a", 80), strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 5) + "\r\n"},
+		{"folded twice", strings.Repeat("a", 150), strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n " + "a" + "\r\n"},
+		{"utf-8 not split", strings.Repeat("a", 74) + "ä", strings.Repeat("a", 74) + "\r\n " + "ä" + "\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var builder strings.Builder
+			writeIcsLine(&builder, test.line)
+			if got := builder.String(); got != test.want {
+				t.Errorf("writeIcsLine() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEscapeIcsText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain", "plain"},
+		{"a,b;c", "a\\,b\\;c"},
+		{"back\\slash", "back\\\\slash"},
+		{"two\nlines", "two\\nlines"},
+		{"two\r\nlines", "two\\nlines"},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if got := escapeIcsText(test.text); got != test.want {
+				t.Errorf("escapeIcsText() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteIcs(t *testing.T) {
+	events := []CalendarEvent{
+		{ID: 1, Name: "Exam, part 1", Description: "<p>Bring a <b>pen</b></p>", CourseID: 7, TimeStart: 1700000000, TimeDuration: 3600, TimeModified: 1690000000, URL: "https://moodle.example/mod/quiz/view.php?id=3"},
+		{ID: 2, Name: "Deadline", CourseID: 8, TimeStart: 1700003600},
+	}
+	filePath := filepath.Join(t.TempDir(), "calendar.ics")
+	if err := writeIcs(filePath, "MATH1", map[int]string{7: "MATH1"}, events, "moodle.example"); err != nil {
+		t.Fatalf("writeIcs() error = %v", err)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:MATH1\r\n",
+		"UID:moodle-1@moodle.example\r\n",
+		"DTSTART:20231114T221320Z\r\n",
+		"DTEND:20231114T231320Z\r\n",
+		"LAST-MODIFIED:20230722T042640Z\r\n",
+		"SUMMARY:Exam\\, part 1\r\n",
+		"DESCRIPTION:Bring a pen\r\n",
+		"CATEGORIES:MATH1\r\n",
+		"URL:https://moodle.example/mod/quiz/view.php?id=3\r\n",
+		"UID:moodle-2@moodle.example\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("calendar does not contain %q:\n%s", want, content)
+		}
+	}
+	if got := strings.Count(content, "LAST-MODIFIED:"); got != 1 {
+		t.Errorf("calendar contains %d LAST-MODIFIED lines, want 1 because the second event has no timemodified", got)
+	}
+	if got := strings.Count(content, "CATEGORIES:"); got != 1 {
+		t.Errorf("calendar contains %d CATEGORIES lines, want 1 because the second course has no name", got)
+	}
+	if strings.Contains(content, "DTSTAMP:19700101") {
+		t.Errorf("DTSTAMP must be the export time:\n%s", content)
+	}
+}
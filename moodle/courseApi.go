@@ -3,13 +3,17 @@ package moodle
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/rivo/tview"
 	"github.com/sirupsen/logrus"
-	"log"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"scar/progress"
 	"scar/util"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // CoursesResponse represents the response from the Moodle endpoint.
@@ -63,10 +67,13 @@ type CourseModule struct {
 // CourseContent represents the content of a module.
 // It includes information such as the type, filename, file size, and URL.
 type CourseContent struct {
-	Type     string `json:"type"`
-	FileName string `json:"filename"`
-	FileSize int64  `json:"filesize"`
-	FileURL  string `json:"fileurl"`
+	Type         string `json:"type"`
+	FileName     string `json:"filename"`
+	FilePath     string `json:"filepath"`
+	FileSize     int64  `json:"filesize"`
+	Content      string `json:"content"`
+	FileURL      string `json:"fileurl"`
+	TimeModified int64  `json:"timemodified"`
 }
 
 // CourseModuleDate represents a date associated with a course module.
@@ -77,9 +84,14 @@ type CourseModuleDate struct {
 	DataID    string `json:"dataid"`
 }
 
+// courseImageName is the file name of the course image inside the course folder without the extension
+const courseImageName = "courseimage"
+
 type CourseApi struct {
 	client      *MoodleClient
 	courseCache CourseCache
+	// calendarMu guards the combined calendar which every course writes
+	calendarMu sync.Mutex
 }
 
 // CourseModAssign contains the data for assignments for one course
@@ -89,21 +101,89 @@ type CourseModAssign struct {
 }
 
 type CourseModAssignment struct {
-	ComponentID         int          `json:"cmid"`
-	AssignmentID        int          `json:"id"`
-	Intro               string       `json:"intro"`
-	SubmissionStatement string       `json:"submissionstatement"`
-	IntroAttachment     []MoodleFile `json:"introattachments"`
+	ComponentID              int          `json:"cmid"`
+	AssignmentID             int          `json:"id"`
+	Intro                    string       `json:"intro"`
+	SubmissionStatement      string       `json:"submissionstatement"`
+	IntroAttachment          []MoodleFile `json:"introattachments"`
+	DueDate                  int64        `json:"duedate"`
+	CutoffDate               int64        `json:"cutoffdate"`
+	AllowSubmissionsFromDate int64        `json:"allowsubmissionsfromdate"`
+}
+
+// The submission states which are saved in the data.json of an assignment
+const (
+	SubmissionStatusNotSubmitted = "notsubmitted"
+	SubmissionStatusDraft        = "draft"
+	SubmissionStatusSubmitted    = "submitted"
+	SubmissionStatusLate         = "late"
+	SubmissionStatusGraded       = "graded"
+)
+
+// AssignSubmissionStatus is the response of mod_assign_get_submission_status
+type AssignSubmissionStatus struct {
+	LastAttempt *struct {
+		Submission       *AssignSubmission `json:"submission"`
+		TeamSubmission   *AssignSubmission `json:"teamsubmission"`
+		GradingStatus    string            `json:"gradingstatus"`
+		ExtensionDueDate int64             `json:"extensionduedate"`
+	} `json:"lastattempt"`
+	Feedback         *AssignFeedback         `json:"feedback"`
+	PreviousAttempts []AssignPreviousAttempt `json:"previousattempts"`
+}
+
+type AssignSubmission struct {
+	ID            int            `json:"id"`
+	AttemptNumber int            `json:"attemptnumber"`
+	Status        string         `json:"status"`
+	TimeCreated   int64          `json:"timecreated"`
+	TimeModified  int64          `json:"timemodified"`
+	Plugins       []AssignPlugin `json:"plugins"`
+}
+
+type AssignPreviousAttempt struct {
+	AttemptNumber int               `json:"attemptnumber"`
+	Submission    *AssignSubmission `json:"submission"`
+	Grade         *struct {
+		Grade        string `json:"grade"`
+		TimeModified int64  `json:"timemodified"`
+	} `json:"grade"`
+	FeedbackPlugins []AssignPlugin `json:"feedbackplugins"`
+}
+
+// AssignFeedback contains the grade and the feedback of the teacher
+type AssignFeedback struct {
+	GradeForDisplay string         `json:"gradefordisplay"`
+	GradedDate      int64          `json:"gradeddate"`
+	Plugins         []AssignPlugin `json:"plugins"`
+}
+
+// AssignPlugin is a submission or feedback plugin. E.g. file, onlinetext or comments
+type AssignPlugin struct {
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	FileAreas []struct {
+		Area  string       `json:"area"`
+		Files []MoodleFile `json:"files"`
+	} `json:"fileareas"`
+	EditorFields []struct {
+		Name string `json:"name"`
+		Text string `json:"text"`
+	} `json:"editorfields"`
 }
 
 type MoodleFile struct {
-	FileName string `json:"filename"`
-	FileSize int64  `json:"filesize"`
-	FileURL  string `json:"fileurl"`
+	FileName     string `json:"filename"`
+	FilePath     string `json:"filepath"`
+	FileSize     int64  `json:"filesize"`
+	FileURL      string `json:"fileurl"`
+	TimeModified int64  `json:"timemodified"`
 }
 
 type CourseCache struct {
 	CourseModAssignments []CourseModAssignment `json:"assignments"`
+	// Courses are the courses of the last GetCourses call
+	Courses []Course `json:"courses"`
 }
 
 type DownloadAssignmentData struct {
@@ -115,6 +195,36 @@ type DownloadAssignmentData struct {
 	SubmissionStatement        string   `json:"submissionstatement"`
 	IntroAttachmentsNames      []string `json:"introattachmentsnames"`
 	SubmissionAttachmentsNames []string `json:"submissionattachmentsnames"`
+	// SubmissionAttachmentsPaths are the paths of the submission files relative to the module folder
+	SubmissionAttachmentsPaths []string `json:"submissionattachmentspaths"`
+	Grade                      string   `json:"grade"`
+	GradedDate                 int64    `json:"gradeddate"`
+	FeedbackComments           string   `json:"feedbackcomments"`
+	FeedbackAttachmentsNames   []string `json:"feedbackattachmentsnames"`
+	FeedbackAttachmentsPaths   []string `json:"feedbackattachmentspaths"`
+	// OnlineText is the online text of the last attempt. Links to images are relative to the module folder
+	OnlineText string `json:"onlinetext"`
+	// Attempts contains the last and all previous attempts, the newest first
+	Attempts                 []DownloadAssignmentAttempt `json:"attempts"`
+	DueDate                  int64                       `json:"duedate"`
+	CutoffDate               int64                       `json:"cutoffdate"`
+	AllowSubmissionsFromDate int64                       `json:"allowsubmissionsfromdate"`
+	ExtensionDueDate         int64                       `json:"extensionduedate"`
+	// SubmissionStatus is one of the SubmissionStatus constants
+	SubmissionStatus string `json:"submissionstatus"`
+	TimeSubmitted    int64  `json:"timesubmitted"`
+}
+
+type DownloadAssignmentAttempt struct {
+	AttemptNumber    int    `json:"attemptnumber"`
+	Status           string `json:"status"`
+	TimeCreated      int64  `json:"timecreated"`
+	TimeModified     int64  `json:"timemodified"`
+	OnlineText       string `json:"onlinetext"`
+	Grade            string `json:"grade"`
+	FeedbackComments string `json:"feedbackcomments"`
+	// Files are the paths of the submitted files relative to the module folder
+	Files []string `json:"files"`
 }
 type DownloadResourceData struct {
 	ID               int      `json:"id"`
@@ -154,11 +264,15 @@ type DownloadCourseSection struct {
 }
 
 func newCourseApi(client *MoodleClient) *CourseApi {
-	return &CourseApi{client, CourseCache{}}
+	return &CourseApi{client: client}
 }
 
 func (courseApi *CourseApi) GetCourses(fetchSectionsInCourse bool) ([]Course, error) {
-	body, err := courseApi.client.makeWebserviceRequest("core_course_get_enrolled_courses_by_timeline_classification", map[string]string{"classification": "all"})
+	filter, err := loadCourseFilter()
+	if err != nil {
+		return nil, err
+	}
+	body, err := courseApi.client.makeWebserviceRequest("core_course_get_enrolled_courses_by_timeline_classification", map[string]string{"classification": strings.ToLower(filter.Classification)})
 
 	if err != nil {
 		return nil, err
@@ -169,16 +283,18 @@ func (courseApi *CourseApi) GetCourses(fetchSectionsInCourse bool) ([]Course, er
 	}
 
 	logrus.Info("Found ", len(coursesResp.Courses), " Course")
-	for i := range coursesResp.Courses {
-		if strings.Contains(coursesResp.Courses[i].CourseImage, "http") {
-			file, err := os.ReadFile("html/moodle/imgs/defaultImgs.svg")
-			if err != nil {
-				logrus.Error("Could not load default svg")
-			}
-			logrus.Infof("%s does not have a image load default", coursesResp.Courses[i].ShortName)
-			coursesResp.Courses[i].CourseImage = string(file)
+	var courses []Course
+	for _, course := range coursesResp.Courses {
+		if filter.Matches(course) {
+			courses = append(courses, course)
 		}
 	}
+	if len(courses) != len(coursesResp.Courses) {
+		logrus.Info(len(coursesResp.Courses)-len(courses), " Courses were filtered out")
+	}
+	coursesResp.Courses = courses
+
+	courseApi.courseCache.Courses = coursesResp.Courses
 
 	if fetchSectionsInCourse {
 		logrus.Info("Fetch sections for Courses")
@@ -252,7 +368,7 @@ func (courseApi *CourseApi) FetchCourseContents(course *Course) error {
 	return nil
 }
 
-func (courseApi *CourseApi) downloadAssignModule(module *CourseModule, basePath string) error {
+func (courseApi *CourseApi) downloadAssignModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
 	if module.ModName != "assign" {
 		logrus.Fatal("Module is not a assignment")
 	}
@@ -260,51 +376,40 @@ func (courseApi *CourseApi) downloadAssignModule(module *CourseModule, basePath
 	if err != nil {
 		return err
 	}
-	var result map[string]interface{}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		log.Fatalf("Error unmarshalling JSON: %v", err)
-	}
-
-	var submissionMoodleFiles []MoodleFile
-	if value, ok := result["lastattempt"]; ok {
-		lastAttempt := value.(map[string]interface{})
-		if value, ok := lastAttempt["submission"]; ok {
-			submission := value.(map[string]interface{})
-			if value, ok := submission["plugins"]; ok {
-				plugins := value.([]interface{})
-
-				for _, plugin := range plugins {
-					pluginMap := plugin.(map[string]interface{})
-					if pluginMap["type"] == "file" {
-						fileAreas := pluginMap["fileareas"].([]interface{})
-						for _, fileArea := range fileAreas {
-							fileAreaMap := fileArea.(map[string]interface{})
-							files := fileAreaMap["files"].([]interface{})
-							for _, file := range files {
-								fileMap := file.(map[string]interface{})
-								moodleFile := MoodleFile{
-									FileName: fileMap["filename"].(string),
-									FileSize: int64(fileMap["filesize"].(float64)),
-									FileURL:  fileMap["fileurl"].(string),
-								}
-								submissionMoodleFiles = append(submissionMoodleFiles, moodleFile)
-							}
-						}
-					}
-				}
-			}
+	var status AssignSubmissionStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return err
+	}
+	var lastSubmission *AssignSubmission
+	if status.LastAttempt != nil {
+		lastSubmission = status.LastAttempt.Submission
+		if lastSubmission == nil {
+			lastSubmission = status.LastAttempt.TeamSubmission
 		}
 	}
+	var submissionMoodleFiles []assignFile
+	var onlineText string
+	if lastSubmission != nil {
+		submissionMoodleFiles = assignPluginFiles(lastSubmission.Plugins)
+		onlineText = assignOnlineText(lastSubmission.Plugins)
+	}
+	var feedbackMoodleFiles []assignFile
+	var feedbackComments string
+	if status.Feedback != nil {
+		feedbackMoodleFiles = assignPluginFiles(status.Feedback.Plugins)
+		feedbackComments = assignEditorText(status.Feedback.Plugins, "")
+	}
 
 	var submissionMoodleFileNames []string
+	var submissionMoodleFilePaths []string
 	for _, file := range submissionMoodleFiles {
-		submissionMoodleFileNames = append(submissionMoodleFileNames, file.FileName)
+		submissionMoodleFileNames = append(submissionMoodleFileNames, file.File.FileName)
+		submissionMoodleFilePaths = append(submissionMoodleFilePaths, "submissions/"+file.RelPath)
 	}
 
 	var courseAssignment = courseApi.getCourseModAssignment(module)
 	if courseAssignment == nil {
-		courseAssignment = &CourseModAssignment{-1, -1, "", "", nil}
+		courseAssignment = &CourseModAssignment{ComponentID: -1, AssignmentID: -1}
 	}
 	var introMoodleFileNames []string
 	for _, file := range courseAssignment.IntroAttachment {
@@ -317,13 +422,59 @@ func (courseApi *CourseApi) downloadAssignModule(module *CourseModule, basePath
 	data.Name = module.Name
 	data.ModName = module.ModName
 	data.SubmissionAttachmentsNames = submissionMoodleFileNames
-	data.Intro = courseAssignment.Intro
-	data.SubmissionStatement = courseAssignment.SubmissionStatement
+	data.SubmissionAttachmentsPaths = submissionMoodleFilePaths
 	data.IntroAttachmentsNames = introMoodleFileNames
+	data.DueDate = courseAssignment.DueDate
+	data.CutoffDate = courseAssignment.CutoffDate
+	data.AllowSubmissionsFromDate = courseAssignment.AllowSubmissionsFromDate
+	data.SubmissionStatus = SubmissionStatusNotSubmitted
+	if status.LastAttempt != nil {
+		data.ExtensionDueDate = status.LastAttempt.ExtensionDueDate
+		data.SubmissionStatus = assignSubmissionStatus(lastSubmission, status.LastAttempt.GradingStatus, data.DueDate, data.ExtensionDueDate)
+	}
+	if lastSubmission != nil && data.SubmissionStatus != SubmissionStatusNotSubmitted && data.SubmissionStatus != SubmissionStatusDraft {
+		data.TimeSubmitted = lastSubmission.TimeModified
+	}
 
-	modulePath := fmt.Sprintf("%s/%d", basePath, module.ID)
-	introFilesPath := fmt.Sprintf("%s/introfiles", modulePath)
-	submissionFilesPath := fmt.Sprintf("%s/submissions", modulePath)
+	modulePath := fmt.Sprintf("%s/%d", basePath, moduleFolderID(module))
+
+	data.Intro = courseApi.localizePluginFiles(courseAssignment.Intro, modulePath, "inline", manifest, reporter)
+	data.SubmissionStatement = courseApi.localizePluginFiles(courseAssignment.SubmissionStatement, modulePath, "inline", manifest, reporter)
+	if status.Feedback != nil {
+		data.Grade = status.Feedback.GradeForDisplay
+		data.GradedDate = status.Feedback.GradedDate
+		data.FeedbackComments = courseApi.localizePluginFiles(feedbackComments, modulePath, "feedback/inline", manifest, reporter)
+		for _, file := range feedbackMoodleFiles {
+			data.FeedbackAttachmentsNames = append(data.FeedbackAttachmentsNames, file.File.FileName)
+			data.FeedbackAttachmentsPaths = append(data.FeedbackAttachmentsPaths, "feedback/"+file.RelPath)
+		}
+	}
+	data.OnlineText = courseApi.localizePluginFiles(onlineText, modulePath, "submissions/inline", manifest, reporter)
+	if lastSubmission != nil {
+		attempt := DownloadAssignmentAttempt{
+			AttemptNumber: lastSubmission.AttemptNumber,
+			Status:        lastSubmission.Status,
+			TimeCreated:   lastSubmission.TimeCreated,
+			TimeModified:  lastSubmission.TimeModified,
+			OnlineText:    data.OnlineText,
+		}
+		attempt.Files = submissionMoodleFilePaths
+		if status.Feedback != nil {
+			attempt.Grade = status.Feedback.GradeForDisplay
+			attempt.FeedbackComments = data.FeedbackComments
+		}
+		data.Attempts = append(data.Attempts, attempt)
+	}
+	for _, previous := range status.PreviousAttempts {
+		attempt, err := courseApi.downloadAssignAttempt(previous, modulePath, manifest, reporter)
+		if err != nil {
+			return err
+		}
+		data.Attempts = append(data.Attempts, attempt)
+	}
+	sort.Slice(data.Attempts, func(i, j int) bool {
+		return data.Attempts[i].AttemptNumber > data.Attempts[j].AttemptNumber
+	})
 
 	err = util.SaveStructToJSON(data, modulePath+"/data.json")
 	if err != nil {
@@ -331,13 +482,19 @@ func (courseApi *CourseApi) downloadAssignModule(module *CourseModule, basePath
 	}
 
 	for _, file := range submissionMoodleFiles {
-		err := courseApi.client.downloadFile(file.FileURL, submissionFilesPath+"/"+file.FileName, file.FileSize)
+		err := courseApi.downloadModuleFile(file.File, modulePath, "submissions/"+file.RelPath, manifest, reporter)
 		if err != nil {
 			return err
 		}
 	}
 	for _, file := range courseAssignment.IntroAttachment {
-		err := courseApi.client.downloadFile(file.FileURL, introFilesPath+"/"+file.FileName, file.FileSize)
+		err := courseApi.downloadModuleFile(file, modulePath, "introfiles/"+file.FileName, manifest, reporter)
+		if err != nil {
+			return err
+		}
+	}
+	for _, file := range feedbackMoodleFiles {
+		err := courseApi.downloadModuleFile(file.File, modulePath, "feedback/"+file.RelPath, manifest, reporter)
 		if err != nil {
 			return err
 		}
@@ -345,9 +502,101 @@ func (courseApi *CourseApi) downloadAssignModule(module *CourseModule, basePath
 	return nil
 }
 
-func (courseApi *CourseApi) downloadResourceModule(module *CourseModule, basePath string) error {
-	modulePath := fmt.Sprintf("%s/%d", basePath, module.ID)
-	contentFilePath := fmt.Sprintf("%s/contents", modulePath)
+// downloadAssignAttempt downloads the files and the online text of a previous attempt into attempts/<attemptnumber>
+func (courseApi *CourseApi) downloadAssignAttempt(previous AssignPreviousAttempt, modulePath string, manifest *ModuleManifest, reporter progress.Reporter) (DownloadAssignmentAttempt, error) {
+	attemptPath := fmt.Sprintf("attempts/%d", previous.AttemptNumber)
+	attempt := DownloadAssignmentAttempt{AttemptNumber: previous.AttemptNumber}
+	if previous.Submission != nil {
+		attempt.Status = previous.Submission.Status
+		attempt.TimeCreated = previous.Submission.TimeCreated
+		attempt.TimeModified = previous.Submission.TimeModified
+		attempt.OnlineText = courseApi.localizePluginFiles(assignOnlineText(previous.Submission.Plugins), modulePath, attemptPath+"/inline", manifest, reporter)
+		for _, file := range assignPluginFiles(previous.Submission.Plugins) {
+			relPath := attemptPath + "/" + file.RelPath
+			if err := courseApi.downloadModuleFile(file.File, modulePath, relPath, manifest, reporter); err != nil {
+				return attempt, err
+			}
+			attempt.Files = append(attempt.Files, relPath)
+		}
+	}
+	// moodle returns -1 for attempts which were not graded
+	if previous.Grade != nil && !strings.HasPrefix(previous.Grade.Grade, "-") {
+		attempt.Grade = previous.Grade.Grade
+	}
+	attempt.FeedbackComments = courseApi.localizePluginFiles(assignEditorText(previous.FeedbackPlugins, ""), modulePath, attemptPath+"/feedback", manifest, reporter)
+	return attempt, nil
+}
+
+// assignSubmissionStatus returns the SubmissionStatus of the last attempt. A submission after the due date
+// (or the extension of the user) is late
+func assignSubmissionStatus(submission *AssignSubmission, gradingStatus string, dueDate int64, extensionDueDate int64) string {
+	if gradingStatus == "graded" {
+		return SubmissionStatusGraded
+	}
+	if submission == nil {
+		return SubmissionStatusNotSubmitted
+	}
+	switch submission.Status {
+	case "submitted":
+		if extensionDueDate > dueDate {
+			dueDate = extensionDueDate
+		}
+		if dueDate != 0 && submission.TimeModified > dueDate {
+			return SubmissionStatusLate
+		}
+		return SubmissionStatusSubmitted
+	case "draft":
+		return SubmissionStatusDraft
+	}
+	return SubmissionStatusNotSubmitted
+}
+
+// assignFile is a file of a submission or feedback plugin
+type assignFile struct {
+	File MoodleFile
+	// RelPath is <filearea>/<filepath>/<filename>. Files of different areas or folders can have the same name
+	RelPath string
+}
+
+// assignPluginFiles returns the files of all file plugins
+func assignPluginFiles(plugins []AssignPlugin) []assignFile {
+	var files []assignFile
+	for _, plugin := range plugins {
+		if plugin.Type != "file" && plugin.Type != "editpdf" {
+			continue
+		}
+		for _, fileArea := range plugin.FileAreas {
+			for _, file := range fileArea.Files {
+				files = append(files, assignFile{File: file, RelPath: treeRelPath(fileArea.Area, file.FilePath, file.FileName)})
+			}
+		}
+	}
+	return files
+}
+
+// assignOnlineText returns the html of the onlinetext plugin
+func assignOnlineText(plugins []AssignPlugin) string {
+	return assignEditorText(plugins, "onlinetext")
+}
+
+// assignEditorText joins the text of all editor fields. If pluginType is not empty only plugins of this type are used
+func assignEditorText(plugins []AssignPlugin, pluginType string) string {
+	var texts []string
+	for _, plugin := range plugins {
+		if pluginType != "" && plugin.Type != pluginType {
+			continue
+		}
+		for _, editorField := range plugin.EditorFields {
+			if strings.TrimSpace(editorField.Text) != "" {
+				texts = append(texts, editorField.Text)
+			}
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func (courseApi *CourseApi) downloadResourceModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
+	modulePath := fmt.Sprintf("%s/%d", basePath, moduleFolderID(module))
 	var contentFileNames []string
 	for _, content := range module.Contents {
 		contentFileNames = append(contentFileNames, content.FileName)
@@ -365,19 +614,14 @@ func (courseApi *CourseApi) downloadResourceModule(module *CourseModule, basePat
 		return err
 	}
 
-	for _, file := range module.Contents {
-		err = courseApi.client.downloadFile(file.FileURL, contentFilePath+"/"+file.FileName, file.FileSize)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-
+	return util.RunParallel(courseApi.client.fileWorkers(), len(module.Contents), func(index int) error {
+		file := module.Contents[index]
+		return courseApi.downloadModuleFile(file.moodleFile(), modulePath, "contents/"+file.FileName, manifest, reporter)
+	})
 }
 
-func (courseApi *CourseApi) downloadUrlModule(module *CourseModule, basePath string) error {
-	modulePath := fmt.Sprintf("%s/%d", basePath, module.ID)
+func (courseApi *CourseApi) downloadUrlModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
+	modulePath := fmt.Sprintf("%s/%d", basePath, moduleFolderID(module))
 
 	var contentUrls []string
 	for _, content := range module.Contents {
@@ -397,15 +641,15 @@ func (courseApi *CourseApi) downloadUrlModule(module *CourseModule, basePath str
 	}
 	return nil
 }
-func (courseApi *CourseApi) downloadLabelModule(module *CourseModule, basePath string) error {
-	modulePath := fmt.Sprintf("%s/%d", basePath, module.ID)
+func (courseApi *CourseApi) downloadLabelModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
+	modulePath := fmt.Sprintf("%s/%d", basePath, moduleFolderID(module))
 
 	var data DownloadLabelData
 	data.ID = module.ID
 	data.CMID = module.ComponentID
 	data.Name = module.Name
 	data.ModName = module.ModName
-	data.Description = module.Description
+	data.Description = courseApi.localizePluginFiles(module.Description, modulePath, "inline", manifest, reporter)
 	err := util.SaveStructToJSON(data, modulePath+"/data.json")
 	if err != nil {
 		return err
@@ -413,20 +657,34 @@ func (courseApi *CourseApi) downloadLabelModule(module *CourseModule, basePath s
 	return nil
 }
 
-func (courseApi *CourseApi) DownloadModule(module *CourseModule, basePath string) error {
+func (courseApi *CourseApi) DownloadModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
 	switch module.ModName {
 	case "label":
-		return courseApi.downloadLabelModule(module, basePath)
+		return courseApi.downloadLabelModule(module, basePath, manifest, reporter)
 	case "resource":
-		return courseApi.downloadResourceModule(module, basePath)
+		return courseApi.downloadResourceModule(module, basePath, manifest, reporter)
 	case "url":
-		return courseApi.downloadUrlModule(module, basePath)
+		return courseApi.downloadUrlModule(module, basePath, manifest, reporter)
 	case "assign":
-		return courseApi.downloadAssignModule(module, basePath)
+		return courseApi.downloadAssignModule(module, basePath, manifest, reporter)
+	case "folder":
+		return courseApi.downloadFolderModule(module, basePath, manifest, reporter)
+	case "page":
+		return courseApi.downloadPageModule(module, basePath, manifest, reporter)
+	case "forum":
+		return courseApi.downloadForumModule(module, basePath, manifest, reporter)
+	case "quiz":
+		return courseApi.downloadQuizModule(module, basePath, manifest, reporter)
+	case "book":
+		return courseApi.downloadBookModule(module, basePath, manifest, reporter)
+	case "glossary":
+		return courseApi.downloadGlossaryModule(module, basePath, manifest, reporter)
+	case "wiki":
+		return courseApi.downloadWikiModule(module, basePath, manifest, reporter)
 	}
 	return nil
 }
-func (courseApi *CourseApi) DownloadCourse(course *Course, basePath string, progressChan chan<- int, view *tview.TextView) error {
+func (courseApi *CourseApi) DownloadCourse(course *Course, basePath string, reporter progress.Reporter) error {
 
 	var coursePath = fmt.Sprintf("%s/%d", basePath, course.ID)
 	var sections []DownloadCourseSection
@@ -442,10 +700,12 @@ func (courseApi *CourseApi) DownloadCourse(course *Course, basePath string, prog
 	data.ID = course.ID
 	data.ShortName = course.ShortName
 	data.Fullname = course.Fullname
-	data.Summary = course.Summary
+	// the course has no manifest for its own files. Files with an unknown size are only downloaded once
+	data.Summary = courseApi.localizePluginFiles(course.Summary, coursePath, "summary", nil, reporter)
 	data.Category = course.Category
 	data.Sections = sections
-	data.CourseImage = course.CourseImage
+	manifest := LoadCourseManifest(coursePath, course.ID)
+	data.CourseImage = courseApi.downloadCourseImage(course, coursePath, manifest, reporter)
 	data.CourseImageType = course.CourseImageType
 
 	err := util.SaveStructToJSON(data, coursePath+"/data.json")
@@ -453,26 +713,129 @@ func (courseApi *CourseApi) DownloadCourse(course *Course, basePath string, prog
 		return err
 	}
 
-	moduleCount := 0
-	var text string
+	migrateModuleFolders(coursePath, course.Sections, reporter)
+
+	var stats syncStats
+	var statsMu sync.Mutex
+	type sectionModule struct {
+		sectionID int
+		module    CourseModule
+	}
+	var modules []sectionModule
 	for _, section := range course.Sections {
 		for _, module := range section.Modules {
-			if strings.Contains(coursePath, "7706") {
-				logrus.Info(section.Name)
-			}
-			err := courseApi.DownloadModule(&module, fmt.Sprintf("%s/%d", coursePath, section.ID))
-			text = "Downloaded: " + module.Name + "\n" + text
-			view.SetText(text)
-			progressChan <- moduleCount
-			if err != nil {
-				logrus.Info("Could not download module: ", err.Error())
+			modules = append(modules, sectionModule{section.ID, module})
+		}
+	}
+	reporter.Started(course.ShortName, len(modules))
+	// a failed module does not stop the other ones. The errors are returned after the course was finished
+	modulesErr := util.RunParallel(util.Config.GetInt("moodle_module_workers", defaultModuleWorkers), len(modules), func(index int) error {
+		module := &modules[index].module
+		sectionPath := fmt.Sprintf("%s/%d", coursePath, modules[index].sectionID)
+		moduleManifest, isNew := manifest.module(module, modules[index].sectionID)
+		timeModified := moduleTimeModified(module)
+		modulePath := fmt.Sprintf("%s/%d", sectionPath, moduleFolderID(module))
+		if !isNew && timeModified != 0 && moduleManifest.TimeModified == timeModified && moduleDownloaded(module, sectionPath) {
+			// dates can change without changing the contents
+			if err := saveInModuleData(modulePath, "dates", module.Dates); err != nil {
+				reporter.Warning("Could not save dates of " + module.Name + ": " + err.Error())
 			}
+			statsMu.Lock()
+			stats.Unchanged++
+			statsMu.Unlock()
+			reporter.ItemDone(module.Name)
+			return nil
+		}
+		err := courseApi.DownloadModule(module, sectionPath, moduleManifest, reporter)
+		if err != nil {
+			logrus.Info("Could not download module: ", err.Error())
+			reporter.Failed(module.Name, err)
+			statsMu.Lock()
+			stats.Failed++
+			statsMu.Unlock()
+			return fmt.Errorf("%s: %w", module.Name, err)
 		}
+		for _, relPath := range moduleManifest.markRemovedFiles() {
+			reporter.Warning(fmt.Sprintf("File was removed from moodle: %s/%s", module.Name, relPath))
+		}
+		if err := saveVersionsInModuleData(modulePath, moduleManifest.Versions); err != nil {
+			reporter.Warning("Could not save versions of " + module.Name + ": " + err.Error())
+		}
+		if err := saveInModuleData(modulePath, "dates", module.Dates); err != nil {
+			reporter.Warning("Could not save dates of " + module.Name + ": " + err.Error())
+		}
+		statsMu.Lock()
+		if isNew {
+			stats.New++
+			reporter.Info("New module: " + module.Name)
+		} else if moduleManifest.TimeModified != timeModified {
+			stats.Changed++
+			reporter.Info("Changed module: " + module.Name)
+		} else {
+			stats.Unchanged++
+		}
+		statsMu.Unlock()
+		moduleManifest.TimeModified = timeModified
+		reporter.ItemDone(module.Name)
+		return nil
+	})
+	if err := courseApi.downloadGrades(course, coursePath); err != nil {
+		reporter.Warning("Could not export grades: " + err.Error())
+	}
+	if err := courseApi.syncCalendar(course, basePath); err != nil {
+		reporter.Warning("Could not export calendar: " + err.Error())
+	}
+	for _, removed := range manifest.markRemovedModules() {
+		stats.Removed++
+		reporter.Warning("Module was removed from moodle: " + removed.Name)
+	}
+	if err := manifest.save(); err != nil {
+		reporter.Warning("Could not save manifest: " + err.Error())
+	}
+	reporter.Info(fmt.Sprintf("%d new, %d changed, %d unchanged, %d removed and %d failed modules", stats.New, stats.Changed, stats.Unchanged, stats.Removed, stats.Failed))
+	reporter.Finished(course.ShortName)
+	if modulesErr != nil {
+		return fmt.Errorf("%d of %d modules failed: %w", stats.Failed, len(modules), modulesErr)
 	}
-	close(progressChan)
 	return nil
 }
 
+// downloadCourseImage downloads the image of the course into the course folder and returns its path relative to the
+// course folder. The image is only downloaded again if its url changed since the last sync.
+// If the course only has a generated pattern or the download fails the default image is returned
+func (courseApi *CourseApi) downloadCourseImage(course *Course, coursePath string, manifest *CourseManifest, reporter progress.Reporter) string {
+	if !strings.HasPrefix(course.CourseImage, "http") {
+		return defaultCourseImage()
+	}
+	imageURL, err := url.Parse(course.CourseImage)
+	if err != nil {
+		reporter.Warning("Invalid course image: " + err.Error())
+		return defaultCourseImage()
+	}
+	fileName := courseImageName + path.Ext(imageURL.Path)
+	imagePath := filepath.Join(coursePath, fileName)
+	if _, err := os.Stat(imagePath); err == nil && manifest.CourseImage == course.CourseImage {
+		logrus.Info("Skip file download ", imagePath)
+		return fileName
+	}
+	_, err = courseApi.client.fetchFile(webserviceFileURL(course.CourseImage), imagePath, reporter)
+	if err != nil {
+		reporter.Warning("Could not download course image: " + err.Error())
+		return defaultCourseImage()
+	}
+	manifest.CourseImage = course.CourseImage
+	return fileName
+}
+
+// defaultCourseImage returns the default image as data url
+func defaultCourseImage() string {
+	file, err := os.ReadFile("html/moodle/imgs/defaultImgs.svg")
+	if err != nil {
+		logrus.Error("Could not load default svg")
+	}
+	return string(file)
+}
+
 func (courseApi *CourseApi) getCourseModAssignment(module *CourseModule) *CourseModAssignment {
 	for _, assignment := range courseApi.courseCache.CourseModAssignments {
 		if assignment.AssignmentID == module.ID {
@@ -0,0 +1,102 @@
+package moodle
+
+/**
+The course filter decides which courses are listed and synced. It is configured with these config keys:
+  moodle_course_classification       all, inprogress, past, future or hidden. Sent to moodle when requesting the courses
+  moodle_course_include              regex which the shortname or the fullname has to match
+  moodle_course_exclude              regex which neither the shortname nor the fullname may match
+  moodle_course_categories           only courses in one of these categories
+  moodle_course_excluded_categories  no courses in one of these categories
+  moodle_course_ids                  only the courses with these ids
+  moodle_course_excluded_ids         no courses with these ids
+Empty values do not filter anything.
+*/
+import (
+	"fmt"
+	"regexp"
+	"scar/util"
+	"strconv"
+	"strings"
+)
+
+var courseClassifications = []string{"all", "allincludinghidden", "inprogress", "past", "future", "favourites", "hidden"}
+
+type CourseFilter struct {
+	Classification     string
+	Include            *regexp.Regexp
+	Exclude            *regexp.Regexp
+	Categories         []string
+	ExcludedCategories []string
+	IDs                []string
+	ExcludedIDs        []string
+}
+
+// loadCourseFilter reads the course filter from the config
+func loadCourseFilter() (CourseFilter, error) {
+	filter := CourseFilter{
+		Classification:     util.Config.GetStringWD("moodle_course_classification", "all"),
+		Categories:         util.Config.GetStringList("moodle_course_categories", []string{}),
+		ExcludedCategories: util.Config.GetStringList("moodle_course_excluded_categories", []string{}),
+		IDs:                util.Config.GetStringList("moodle_course_ids", []string{}),
+		ExcludedIDs:        util.Config.GetStringList("moodle_course_excluded_ids", []string{}),
+	}
+	if filter.Classification == "" {
+		filter.Classification = "all"
+	}
+	if !containsFold(courseClassifications, filter.Classification) {
+		return filter, fmt.Errorf("invalid moodle_course_classification %q. Valid are %s", filter.Classification, strings.Join(courseClassifications, ", "))
+	}
+	var err error
+	if filter.Include, err = compileCourseRegex("moodle_course_include"); err != nil {
+		return filter, err
+	}
+	if filter.Exclude, err = compileCourseRegex("moodle_course_exclude"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func compileCourseRegex(key string) (*regexp.Regexp, error) {
+	pattern := util.Config.GetStringWD(key, "")
+	if pattern == "" {
+		return nil, nil
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return regex, nil
+}
+
+// Matches returns true if the course passes all filters
+func (filter CourseFilter) Matches(course Course) bool {
+	id := strconv.Itoa(course.ID)
+	if len(filter.IDs) != 0 && !containsFold(filter.IDs, id) {
+		return false
+	}
+	if containsFold(filter.ExcludedIDs, id) {
+		return false
+	}
+	if len(filter.Categories) != 0 && !containsFold(filter.Categories, course.Category) {
+		return false
+	}
+	if containsFold(filter.ExcludedCategories, course.Category) {
+		return false
+	}
+	if filter.Include != nil && !filter.Include.MatchString(course.ShortName) && !filter.Include.MatchString(course.Fullname) {
+		return false
+	}
+	if filter.Exclude != nil && (filter.Exclude.MatchString(course.ShortName) || filter.Exclude.MatchString(course.Fullname)) {
+		return false
+	}
+	return true
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
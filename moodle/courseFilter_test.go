@@ -0,0 +1,36 @@
+package moodle
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestCourseFilterMatches(t *testing.T) {
+	course := Course{ID: 42, ShortName: "MATH1", Fullname: "Mathematics 1", Category: "Science"}
+	tests := []struct {
+		name   string
+		filter CourseFilter
+		want   bool
+	}{
+		{"empty filter", CourseFilter{}, true},
+		{"id included", CourseFilter{IDs: []string{"41", "42"}}, true},
+		{"id not included", CourseFilter{IDs: []string{"41"}}, false},
+		{"id excluded", CourseFilter{ExcludedIDs: []string{" 42 "}}, false},
+		{"category included", CourseFilter{Categories: []string{"science"}}, true},
+		{"category not included", CourseFilter{Categories: []string{"Languages"}}, false},
+		{"category excluded", CourseFilter{ExcludedCategories: []string{"Science"}}, false},
+		{"include matches shortname", CourseFilter{Include: regexp.MustCompile("^MATH")}, true},
+		{"include matches fullname", CourseFilter{Include: regexp.MustCompile("Mathematics")}, true},
+		{"include does not match", CourseFilter{Include: regexp.MustCompile("^PHYS")}, false},
+		{"exclude matches fullname", CourseFilter{Exclude: regexp.MustCompile("1$")}, false},
+		{"exclude does not match", CourseFilter{Exclude: regexp.MustCompile("^PHYS")}, true},
+		{"all filters pass", CourseFilter{IDs: []string{"42"}, Categories: []string{"Science"}, Include: regexp.MustCompile("MATH"), Exclude: regexp.MustCompile("PHYS")}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.filter.Matches(course); got != test.want {
+				t.Errorf("Matches() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
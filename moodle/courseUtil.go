@@ -1,5 +1,15 @@
 package moodle
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"scar/progress"
+	"scar/util"
+)
+
 // GetAllModules
 // Returns all the modules from a course.
 func GetAllModules(course *Course) []CourseModule {
@@ -11,3 +21,106 @@ func GetAllModules(course *Course) []CourseModule {
 	}
 	return modules
 }
+
+// moduleTimeModified returns the newest timemodified of the module contents.
+// Assignments return 0 because submissions and grades can change without changing the contents
+func moduleTimeModified(module *CourseModule) int64 {
+	if module.ModName == "assign" {
+		return 0
+	}
+	var timeModified int64
+	for _, content := range module.Contents {
+		if content.TimeModified > timeModified {
+			timeModified = content.TimeModified
+		}
+	}
+	return timeModified
+}
+
+// moduleDownloaded returns true if the data.json of the module exists in the section folder
+func moduleDownloaded(module *CourseModule, sectionPath string) bool {
+	_, err := os.Stat(fmt.Sprintf("%s/%d/data.json", sectionPath, moduleFolderID(module)))
+	return err == nil
+}
+
+// moduleFolderID returns the name of the folder of the module inside its section folder. The instance id is only unique
+// per module type, so the course module id (cmid) is used
+func moduleFolderID(module *CourseModule) int {
+	return module.ComponentID
+}
+
+// migrateModuleFolders renames module folders which older versions named by the instance id to the cmid. A folder is
+// only renamed if its data.json belongs to the module. A rename can free the folder another module needs, so the
+// renaming is repeated until nothing changes
+func migrateModuleFolders(coursePath string, sections []CourseSection, reporter progress.Reporter) {
+	for renamed := true; renamed; {
+		renamed = false
+		for _, section := range sections {
+			sectionPath := fmt.Sprintf("%s/%d", coursePath, section.ID)
+			for i := range section.Modules {
+				module := &section.Modules[i]
+				if module.ID == moduleFolderID(module) {
+					continue
+				}
+				oldPath := fmt.Sprintf("%s/%d", sectionPath, module.ID)
+				newPath := fmt.Sprintf("%s/%d", sectionPath, moduleFolderID(module))
+				if _, err := os.Stat(newPath); err == nil || !isModuleFolder(oldPath, module) {
+					continue
+				}
+				if err := os.Rename(oldPath, newPath); err != nil {
+					reporter.Warning("Could not move module folder " + oldPath + ": " + err.Error())
+					continue
+				}
+				renamed = true
+			}
+		}
+	}
+}
+
+// isModuleFolder returns true if the data.json in modulePath was saved for the module
+func isModuleFolder(modulePath string, module *CourseModule) bool {
+	data, err := os.ReadFile(filepath.Join(modulePath, "data.json"))
+	if err != nil {
+		return false
+	}
+	var saved struct {
+		ID      int    `json:"id"`
+		CMID    int    `json:"cmid"`
+		ModName string `json:"modname"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return false
+	}
+	return saved.ID == module.ID && saved.ModName == module.ModName && (saved.CMID == 0 || saved.CMID == module.ComponentID)
+}
+
+func (content CourseContent) moodleFile() MoodleFile {
+	return MoodleFile{
+		FileName:     content.FileName,
+		FileSize:     content.FileSize,
+		FileURL:      content.FileURL,
+		TimeModified: content.TimeModified,
+	}
+}
+
+// saveInModuleData sets key in the data.json of the module to value. The file is only written if the value changed
+func saveInModuleData(modulePath string, key string, value interface{}) error {
+	dataPath := filepath.Join(modulePath, "data.json")
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		return err
+	}
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	newValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(result[key], newValue) {
+		return nil
+	}
+	result[key] = newValue
+	return util.SaveStructToJSON(result, dataPath)
+}
@@ -0,0 +1,43 @@
+package moodle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateModuleFolders(t *testing.T) {
+	coursePath := t.TempDir()
+	sectionPath := filepath.Join(coursePath, "1")
+	// the label 300 has to move away before the resource can take its folder
+	writeTestFile(t, filepath.Join(sectionPath, "57", "data.json"), `{"id": 57, "name": "Script", "modname": "resource"}`)
+	writeTestFile(t, filepath.Join(sectionPath, "300", "data.json"), `{"id": 300, "cmid": 400, "name": "Notes", "modname": "label"}`)
+	// a folder of another module with the same id is not moved
+	writeTestFile(t, filepath.Join(sectionPath, "60", "data.json"), `{"id": 7, "cmid": 60, "name": "Files", "modname": "folder"}`)
+	sections := []CourseSection{{ID: 1, Modules: []CourseModule{
+		{ID: 57, ComponentID: 300, ModName: "resource"},
+		{ID: 300, ComponentID: 400, ModName: "label"},
+		{ID: 60, ComponentID: 500, ModName: "url"},
+		{ID: 7, ComponentID: 60, ModName: "folder"},
+	}}}
+
+	migrateModuleFolders(coursePath, sections, nil)
+
+	for folder, modName := range map[int]string{300: "resource", 400: "label", 60: "folder"} {
+		module := &CourseModule{ModName: modName}
+		for _, m := range sections[0].Modules {
+			if m.ModName == modName {
+				module = &m
+			}
+		}
+		if !isModuleFolder(filepath.Join(sectionPath, fmt.Sprint(folder)), module) {
+			t.Errorf("folder %d does not contain the %s", folder, modName)
+		}
+	}
+	for _, folder := range []string{"57", "500"} {
+		if _, err := os.Stat(filepath.Join(sectionPath, folder)); !os.IsNotExist(err) {
+			t.Errorf("folder %s should not exist", folder)
+		}
+	}
+}
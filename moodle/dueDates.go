@@ -0,0 +1,75 @@
+package moodle
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"scar/provider"
+	"scar/util"
+	"sort"
+	"strconv"
+)
+
+// DueDates reads the due dates and submission states of all downloaded assignments
+func (mp *MoodleProvider) DueDates(itemIDs []string) ([]provider.DueDate, error) {
+	moodlePath := filepath.Join(util.Config.GetString("save_path"), "moodle")
+	if len(itemIDs) == 0 {
+		entries, err := os.ReadDir(moodlePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if _, err := strconv.Atoi(entry.Name()); entry.IsDir() && err == nil {
+				itemIDs = append(itemIDs, entry.Name())
+			}
+		}
+	}
+	var dueDates []provider.DueDate
+	for _, id := range itemIDs {
+		courseDueDates, err := readCourseDueDates(filepath.Join(moodlePath, id))
+		if err != nil {
+			return nil, err
+		}
+		dueDates = append(dueDates, courseDueDates...)
+	}
+	sort.SliceStable(dueDates, func(i, j int) bool {
+		return dueDates[i].DueDate < dueDates[j].DueDate
+	})
+	return dueDates, nil
+}
+
+// readCourseDueDates reads the data.json of every assignment in the course folder
+func readCourseDueDates(coursePath string) ([]provider.DueDate, error) {
+	var course DownloadCourse
+	data, err := os.ReadFile(filepath.Join(coursePath, "data.json"))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &course); err != nil {
+		return nil, err
+	}
+	modulePaths, err := filepath.Glob(filepath.Join(coursePath, "*", "*", "data.json"))
+	if err != nil {
+		return nil, err
+	}
+	var dueDates []provider.DueDate
+	for _, modulePath := range modulePaths {
+		data, err := os.ReadFile(modulePath)
+		if err != nil {
+			continue
+		}
+		var assignment DownloadAssignmentData
+		if err := json.Unmarshal(data, &assignment); err != nil || assignment.ModName != "assign" {
+			continue
+		}
+		dueDates = append(dueDates, provider.DueDate{
+			Item:       strconv.Itoa(course.ID),
+			ItemName:   course.ShortName,
+			Name:       assignment.Name,
+			DueDate:    assignment.DueDate,
+			CutoffDate: assignment.CutoffDate,
+			Status:     assignment.SubmissionStatus,
+		})
+	}
+	return dueDates, nil
+}
@@ -0,0 +1,110 @@
+package moodle
+
+import (
+	"fmt"
+	"path"
+	"scar/progress"
+	"scar/util"
+	"sort"
+	"strings"
+)
+
+type DownloadFolderData struct {
+	ID          int         `json:"id"`
+	CMID        int         `json:"cmid"`
+	Name        string      `json:"name"`
+	ModName     string      `json:"modname"`
+	Description string      `json:"description"`
+	Tree        *FolderNode `json:"tree"`
+}
+
+// FolderNode is a file or a directory of a folder module. Path is relative to the module folder
+type FolderNode struct {
+	Name     string        `json:"name"`
+	Path     string        `json:"path"`
+	IsDir    bool          `json:"isdir"`
+	Size     int64         `json:"size"`
+	Children []*FolderNode `json:"children"`
+}
+
+// contentRelPath returns the path of a content file inside the contents folder of a module.
+// The filepath from moodle always starts and ends with a slash
+func contentRelPath(content CourseContent) string {
+	return fileTreeRelPath("contents", content)
+}
+
+// fileTreeRelPath returns the path of a file inside dir with the directories of its filepath
+func fileTreeRelPath(dir string, content CourseContent) string {
+	return treeRelPath(dir, content.FilePath, content.FileName)
+}
+
+// treeRelPath returns the path of a file inside dir with the directories of its moodle filepath
+func treeRelPath(dir string, filePath string, fileName string) string {
+	return path.Join(dir, path.Clean("/"+filePath), strings.ReplaceAll(fileName, "/", "-"))
+}
+
+func (courseApi *CourseApi) downloadFolderModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
+	modulePath := fmt.Sprintf("%s/%d", basePath, moduleFolderID(module))
+
+	var data DownloadFolderData
+	data.ID = module.ID
+	data.CMID = module.ComponentID
+	data.Name = module.Name
+	data.ModName = module.ModName
+	data.Description = courseApi.localizePluginFiles(module.Description, modulePath, "inline", manifest, reporter)
+	data.Tree = buildFolderTree(module.Contents)
+
+	err := util.SaveStructToJSON(data, modulePath+"/data.json")
+	if err != nil {
+		return err
+	}
+
+	var files []CourseContent
+	for _, content := range module.Contents {
+		if content.Type == "file" {
+			files = append(files, content)
+		}
+	}
+	return util.RunParallel(courseApi.client.fileWorkers(), len(files), func(index int) error {
+		return courseApi.downloadModuleFile(files[index].moodleFile(), modulePath, contentRelPath(files[index]), manifest, reporter)
+	})
+}
+
+// buildFolderTree creates the directory tree of the folder contents. Directories are sorted before files
+func buildFolderTree(contents []CourseContent) *FolderNode {
+	return buildFileTree("contents", contents)
+}
+
+// buildFileTree creates the directory tree of files which are stored with fileTreeRelPath inside dir
+func buildFileTree(dir string, contents []CourseContent) *FolderNode {
+	root := &FolderNode{Name: "/", Path: dir, IsDir: true}
+	dirs := map[string]*FolderNode{dir: root}
+	var getDir func(dirPath string) *FolderNode
+	getDir = func(dirPath string) *FolderNode {
+		if dir, ok := dirs[dirPath]; ok {
+			return dir
+		}
+		parent := getDir(path.Dir(dirPath))
+		dir := &FolderNode{Name: path.Base(dirPath), Path: dirPath, IsDir: true}
+		parent.Children = append(parent.Children, dir)
+		dirs[dirPath] = dir
+		return dir
+	}
+	for _, content := range contents {
+		if content.Type != "file" {
+			continue
+		}
+		relPath := fileTreeRelPath(dir, content)
+		parent := getDir(path.Dir(relPath))
+		parent.Children = append(parent.Children, &FolderNode{Name: path.Base(relPath), Path: relPath, Size: content.FileSize})
+	}
+	for _, dir := range dirs {
+		sort.Slice(dir.Children, func(i, j int) bool {
+			if dir.Children[i].IsDir != dir.Children[j].IsDir {
+				return dir.Children[i].IsDir
+			}
+			return dir.Children[i].Name < dir.Children[j].Name
+		})
+	}
+	return root
+}
@@ -0,0 +1,205 @@
+package moodle
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"scar/progress"
+	"scar/util"
+	"strconv"
+)
+
+const forumDiscussionsPerPage = 100
+
+type ForumDiscussionsResponse struct {
+	Discussions []ForumDiscussion `json:"discussions"`
+	Error       string            `json:"error"`
+}
+
+type ForumDiscussion struct {
+	ID           int    `json:"id"`
+	DiscussionID int    `json:"discussion"`
+	Name         string `json:"name"`
+	UserFullName string `json:"userfullname"`
+	Created      int64  `json:"created"`
+	TimeModified int64  `json:"timemodified"`
+	NumReplies   int    `json:"numreplies"`
+	Pinned       bool   `json:"pinned"`
+	Locked       bool   `json:"locked"`
+}
+
+type ForumPostsResponse struct {
+	Posts []ForumPost `json:"posts"`
+	Error string      `json:"error"`
+}
+
+type ForumPost struct {
+	ID           int    `json:"id"`
+	Subject      string `json:"subject"`
+	Message      string `json:"message"`
+	DiscussionID int    `json:"discussionid"`
+	HasParent    bool   `json:"hasparent"`
+	ParentID     int    `json:"parentid"`
+	TimeCreated  int64  `json:"timecreated"`
+	IsDeleted    bool   `json:"isdeleted"`
+	Author       struct {
+		ID       int    `json:"id"`
+		FullName string `json:"fullname"`
+	} `json:"author"`
+	Attachments []struct {
+		FileName     string `json:"filename"`
+		FilePath     string `json:"filepath"`
+		FileSize     int64  `json:"filesize"`
+		URL          string `json:"url"`
+		TimeModified int64  `json:"timemodified"`
+	} `json:"attachments"`
+	MessageInlineFiles []MoodleInlineFile `json:"messageinlinefiles"`
+}
+
+type DownloadForumData struct {
+	ID          int                       `json:"id"`
+	CMID        int                       `json:"cmid"`
+	Name        string                    `json:"name"`
+	ModName     string                    `json:"modname"`
+	Description string                    `json:"description"`
+	Discussions []DownloadForumDiscussion `json:"discussions"`
+}
+
+type DownloadForumDiscussion struct {
+	ID           int                 `json:"id"`
+	Name         string              `json:"name"`
+	Author       string              `json:"author"`
+	Created      int64               `json:"created"`
+	TimeModified int64               `json:"timemodified"`
+	Pinned       bool                `json:"pinned"`
+	Locked       bool                `json:"locked"`
+	Posts        []DownloadForumPost `json:"posts"`
+}
+
+type DownloadForumPost struct {
+	ID          int    `json:"id"`
+	ParentID    int    `json:"parentid"`
+	Subject     string `json:"subject"`
+	Message     string `json:"message"`
+	Author      string `json:"author"`
+	AuthorID    int    `json:"authorid"`
+	TimeCreated int64  `json:"timecreated"`
+	Deleted     bool   `json:"deleted"`
+	// Attachments are the paths of the attachments relative to the module folder
+	Attachments []string `json:"attachments"`
+}
+
+func (courseApi *CourseApi) getForumDiscussions(forumID int) ([]ForumDiscussion, error) {
+	var discussions []ForumDiscussion
+	for page := 0; ; page++ {
+		body, err := courseApi.client.makeWebserviceRequest("mod_forum_get_forum_discussions", map[string]string{
+			"forumid": strconv.Itoa(forumID),
+			"page":    strconv.Itoa(page),
+			"perpage": strconv.Itoa(forumDiscussionsPerPage),
+		})
+		if err != nil {
+			return nil, err
+		}
+		var response ForumDiscussionsResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		if response.Error != "" {
+			return nil, fmt.Errorf("%v", response.Error)
+		}
+		discussions = append(discussions, response.Discussions...)
+		if len(response.Discussions) < forumDiscussionsPerPage {
+			return discussions, nil
+		}
+	}
+}
+
+func (courseApi *CourseApi) getDiscussionPosts(discussionID int) ([]ForumPost, error) {
+	body, err := courseApi.client.makeWebserviceRequest("mod_forum_get_discussion_posts", map[string]string{
+		"discussionid":  strconv.Itoa(discussionID),
+		"sortby":        "created",
+		"sortdirection": "ASC",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response ForumPostsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%v", response.Error)
+	}
+	return response.Posts, nil
+}
+
+func (courseApi *CourseApi) downloadForumModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
+	modulePath := fmt.Sprintf("%s/%d", basePath, moduleFolderID(module))
+	discussions, err := courseApi.getForumDiscussions(module.ID)
+	if err != nil {
+		return err
+	}
+
+	var data DownloadForumData
+	data.ID = module.ID
+	data.CMID = module.ComponentID
+	data.Name = module.Name
+	data.ModName = module.ModName
+	data.Description = courseApi.localizePluginFiles(module.Description, modulePath, "inline", manifest, reporter)
+
+	for _, discussion := range discussions {
+		posts, err := courseApi.getDiscussionPosts(discussion.DiscussionID)
+		if err != nil {
+			reporter.Warning(fmt.Sprintf("Could not get posts of discussion %s: %s", discussion.Name, err))
+			continue
+		}
+		downloadDiscussion := DownloadForumDiscussion{
+			ID:           discussion.DiscussionID,
+			Name:         discussion.Name,
+			Author:       discussion.UserFullName,
+			Created:      discussion.Created,
+			TimeModified: discussion.TimeModified,
+			Pinned:       discussion.Pinned,
+			Locked:       discussion.Locked,
+		}
+		for _, post := range posts {
+			downloadPost, err := courseApi.downloadForumPost(post, modulePath, manifest, reporter)
+			if err != nil {
+				return err
+			}
+			downloadDiscussion.Posts = append(downloadDiscussion.Posts, downloadPost)
+		}
+		data.Discussions = append(data.Discussions, downloadDiscussion)
+	}
+
+	return util.SaveStructToJSON(data, modulePath+"/data.json")
+}
+
+// downloadForumPost downloads the attachments and inline files of a post into discussions/<discussion>/<post>
+func (courseApi *CourseApi) downloadForumPost(post ForumPost, modulePath string, manifest *ModuleManifest, reporter progress.Reporter) (DownloadForumPost, error) {
+	postPath := fmt.Sprintf("discussions/%d/%d", post.DiscussionID, post.ID)
+	downloadPost := DownloadForumPost{
+		ID:          post.ID,
+		ParentID:    post.ParentID,
+		Subject:     post.Subject,
+		Author:      post.Author.FullName,
+		AuthorID:    post.Author.ID,
+		TimeCreated: post.TimeCreated,
+		Deleted:     post.IsDeleted,
+	}
+	for _, attachment := range post.Attachments {
+		relPath := path.Join(postPath, attachment.FileName)
+		file := MoodleFile{FileName: attachment.FileName, FileSize: attachment.FileSize, FileURL: webserviceFileURL(attachment.URL), TimeModified: attachment.TimeModified}
+		if err := courseApi.downloadModuleFile(file, modulePath, relPath, manifest, reporter); err != nil {
+			return downloadPost, err
+		}
+		downloadPost.Attachments = append(downloadPost.Attachments, relPath)
+	}
+
+	message, err := courseApi.downloadInlineFiles(post.Message, post.MessageInlineFiles, modulePath, path.Join(postPath, "inline"), manifest, reporter)
+	if err != nil {
+		return downloadPost, err
+	}
+	downloadPost.Message = message
+	return downloadPost, nil
+}
@@ -0,0 +1,181 @@
+package moodle
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"scar/progress"
+	"scar/util"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const glossaryEntriesPerRequest = 100
+
+// glossaryShowAllCategories is the category id which returns the entries of all categories (GLOSSARY_SHOW_ALL_CATEGORIES).
+// -1 would only return the entries without a category
+const glossaryShowAllCategories = "0"
+
+type GlossaryEntriesResponse struct {
+	Count   int             `json:"count"`
+	Entries []GlossaryEntry `json:"entries"`
+	Error   string          `json:"error"`
+}
+
+type GlossaryEntry struct {
+	ID                    int                `json:"id"`
+	Concept               string             `json:"concept"`
+	Definition            string             `json:"definition"`
+	UserFullName          string             `json:"userfullname"`
+	TimeCreated           int64              `json:"timecreated"`
+	TimeModified          int64              `json:"timemodified"`
+	Approved              bool               `json:"approved"`
+	Aliases               glossaryAliases    `json:"aliases"`
+	CategoryID            int                `json:"categoryid"`
+	CategoryName          string             `json:"categoryname"`
+	DefinitionInlineFiles []MoodleInlineFile `json:"definitioninlinefiles"`
+	Attachments           []MoodleInlineFile `json:"attachments"`
+}
+
+// glossaryAliases accepts the aliases as list of strings or as list of objects with an alias field
+type glossaryAliases []string
+
+func (aliases *glossaryAliases) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err == nil {
+		*aliases = names
+		return nil
+	}
+	var objects []struct {
+		Alias string `json:"alias"`
+	}
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil
+	}
+	for _, object := range objects {
+		*aliases = append(*aliases, object.Alias)
+	}
+	return nil
+}
+
+type DownloadGlossaryData struct {
+	ID          int                     `json:"id"`
+	CMID        int                     `json:"cmid"`
+	Name        string                  `json:"name"`
+	ModName     string                  `json:"modname"`
+	Description string                  `json:"description"`
+	Entries     []DownloadGlossaryEntry `json:"entries"`
+}
+
+type DownloadGlossaryEntry struct {
+	ID           int    `json:"id"`
+	Concept      string `json:"concept"`
+	Definition   string `json:"definition"`
+	Author       string `json:"author"`
+	TimeCreated  int64  `json:"timecreated"`
+	TimeModified int64  `json:"timemodified"`
+	Approved     bool   `json:"approved"`
+	// Aliases are other terms for the concept
+	Aliases    []string `json:"aliases"`
+	Categories []string `json:"categories"`
+	// Attachments are the paths of the attachments relative to the module folder
+	Attachments []string `json:"attachments"`
+}
+
+// getGlossaryEntries requests all entries of a glossary page by page
+func (courseApi *CourseApi) getGlossaryEntries(function string, params map[string]string) ([]GlossaryEntry, error) {
+	var entries []GlossaryEntry
+	for from := 0; ; from += glossaryEntriesPerRequest {
+		params["from"] = strconv.Itoa(from)
+		params["limit"] = strconv.Itoa(glossaryEntriesPerRequest)
+		body, err := courseApi.client.makeWebserviceRequest(function, params)
+		if err != nil {
+			return nil, err
+		}
+		var response GlossaryEntriesResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		if response.Error != "" {
+			return nil, fmt.Errorf("%v", response.Error)
+		}
+		entries = append(entries, response.Entries...)
+		if len(response.Entries) < glossaryEntriesPerRequest || len(entries) >= response.Count {
+			return entries, nil
+		}
+	}
+}
+
+// getGlossaryCategories returns the names of the categories of every entry
+func (courseApi *CourseApi) getGlossaryCategories(glossaryID int) (map[int][]string, error) {
+	entries, err := courseApi.getGlossaryEntries("mod_glossary_get_entries_by_category", map[string]string{
+		"id":         strconv.Itoa(glossaryID),
+		"categoryid": glossaryShowAllCategories,
+	})
+	if err != nil {
+		return nil, err
+	}
+	categories := map[int][]string{}
+	for _, entry := range entries {
+		if entry.CategoryName != "" {
+			categories[entry.ID] = append(categories[entry.ID], entry.CategoryName)
+		}
+	}
+	return categories, nil
+}
+
+func (courseApi *CourseApi) downloadGlossaryModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
+	modulePath := fmt.Sprintf("%s/%d", basePath, moduleFolderID(module))
+	entries, err := courseApi.getGlossaryEntries("mod_glossary_get_entries_by_letter", map[string]string{
+		"id":     strconv.Itoa(module.ID),
+		"letter": "ALL",
+	})
+	if err != nil {
+		return err
+	}
+	categories, err := courseApi.getGlossaryCategories(module.ID)
+	if err != nil {
+		reporter.Warning(fmt.Sprintf("Could not get the categories of glossary %s: %s", module.Name, err))
+	}
+
+	var data DownloadGlossaryData
+	data.ID = module.ID
+	data.CMID = module.ComponentID
+	data.Name = module.Name
+	data.ModName = module.ModName
+	data.Description = courseApi.localizePluginFiles(module.Description, modulePath, "inline", manifest, reporter)
+
+	for _, entry := range entries {
+		entryPath := fmt.Sprintf("entries/%d", entry.ID)
+		downloadEntry := DownloadGlossaryEntry{
+			ID:           entry.ID,
+			Concept:      entry.Concept,
+			Author:       entry.UserFullName,
+			TimeCreated:  entry.TimeCreated,
+			TimeModified: entry.TimeModified,
+			Approved:     entry.Approved,
+			Aliases:      entry.Aliases,
+			Categories:   categories[entry.ID],
+		}
+		for _, attachment := range entry.Attachments {
+			relPath := path.Join(entryPath, "attachments", attachment.FileName)
+			file := MoodleFile{FileName: attachment.FileName, FileSize: attachment.FileSize, FileURL: webserviceFileURL(attachment.FileURL), TimeModified: attachment.TimeModified}
+			if err := courseApi.downloadModuleFile(file, modulePath, relPath, manifest, reporter); err != nil {
+				return err
+			}
+			downloadEntry.Attachments = append(downloadEntry.Attachments, relPath)
+		}
+		definition, err := courseApi.downloadInlineFiles(entry.Definition, entry.DefinitionInlineFiles, modulePath, path.Join(entryPath, "inline"), manifest, reporter)
+		if err != nil {
+			return err
+		}
+		downloadEntry.Definition = definition
+		data.Entries = append(data.Entries, downloadEntry)
+	}
+	sort.Slice(data.Entries, func(i, j int) bool {
+		return strings.ToLower(data.Entries[i].Concept) < strings.ToLower(data.Entries[j].Concept)
+	})
+
+	return util.SaveStructToJSON(data, modulePath+"/data.json")
+}
@@ -0,0 +1,142 @@
+package moodle
+
+/**
+Exports the grades of the user from the moodle gradebook. Every course gets a grades.json and a grades.csv in its folder.
+*/
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"scar/util"
+	"strconv"
+	"time"
+)
+
+const (
+	gradesFileName    = "grades.json"
+	gradesCsvFileName = "grades.csv"
+)
+
+type GradeItemsResponse struct {
+	UserGrades []struct {
+		CourseID   int         `json:"courseid"`
+		GradeItems []GradeItem `json:"gradeitems"`
+	} `json:"usergrades"`
+	Error string `json:"error"`
+}
+
+// GradeItem is one row of the user grade report. ItemType is mod, manual, category or course (the total)
+type GradeItem struct {
+	ID                   int      `json:"id"`
+	ItemName             string   `json:"itemname"`
+	ItemType             string   `json:"itemtype"`
+	ItemModule           string   `json:"itemmodule"`
+	ItemInstance         int      `json:"iteminstance"`
+	CMID                 int      `json:"cmid"`
+	WeightFormatted      string   `json:"weightformatted"`
+	GradeRaw             *float64 `json:"graderaw"`
+	GradeFormatted       string   `json:"gradeformatted"`
+	GradeMin             float64  `json:"grademin"`
+	GradeMax             float64  `json:"grademax"`
+	RangeFormatted       string   `json:"rangeformatted"`
+	PercentageFormatted  string   `json:"percentageformatted"`
+	LetterGradeFormatted string   `json:"lettergradeformatted"`
+	GradeDateGraded      int64    `json:"gradedategraded"`
+	Feedback             string   `json:"feedback"`
+}
+
+// CourseGrades is the content of the grades.json of a course
+type CourseGrades struct {
+	CourseID   int         `json:"courseid"`
+	ShortName  string      `json:"shortname"`
+	Fullname   string      `json:"fullname"`
+	ExportedAt int64       `json:"exportedat"`
+	Items      []GradeItem `json:"items"`
+}
+
+// displayName returns the name of the item. Moodle returns no name for category and course totals
+func (item GradeItem) displayName() string {
+	if item.ItemName != "" {
+		return item.ItemName
+	}
+	switch item.ItemType {
+	case "course":
+		return "Course total"
+	case "category":
+		return "Category total"
+	}
+	return ""
+}
+
+func (courseApi *CourseApi) GetGradeItems(courseID int) ([]GradeItem, error) {
+	body, err := courseApi.client.makeWebserviceRequest("gradereport_user_get_grade_items", map[string]string{
+		"courseid": strconv.Itoa(courseID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response GradeItemsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%v", response.Error)
+	}
+	var items []GradeItem
+	for _, userGrades := range response.UserGrades {
+		items = append(items, userGrades.GradeItems...)
+	}
+	return items, nil
+}
+
+// downloadGrades saves the grades of the course as grades.json and grades.csv into the course folder
+func (courseApi *CourseApi) downloadGrades(course *Course, coursePath string) error {
+	items, err := courseApi.GetGradeItems(course.ID)
+	if err != nil {
+		return err
+	}
+	grades := CourseGrades{
+		CourseID:   course.ID,
+		ShortName:  course.ShortName,
+		Fullname:   course.Fullname,
+		ExportedAt: time.Now().Unix(),
+		Items:      items,
+	}
+	if err := util.SaveStructToJSON(grades, filepath.Join(coursePath, gradesFileName)); err != nil {
+		return err
+	}
+	return saveGradesCsv(grades, filepath.Join(coursePath, gradesCsvFileName))
+}
+
+func saveGradesCsv(grades CourseGrades, filePath string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	err = writer.Write([]string{"Item", "Type", "Weight", "Grade", "Range", "Percentage", "Letter", "Graded", "Feedback"})
+	if err != nil {
+		return err
+	}
+	for _, item := range grades.Items {
+		err := writer.Write([]string{
+			item.displayName(),
+			item.ItemType,
+			item.WeightFormatted,
+			item.GradeFormatted,
+			item.RangeFormatted,
+			item.PercentageFormatted,
+			item.LetterGradeFormatted,
+			formatTimestamp(item.GradeDateGraded),
+			item.Feedback,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
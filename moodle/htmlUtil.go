@@ -0,0 +1,232 @@
+package moodle
+
+/**
+Helpers to rewrite the links inside the html which moodle returns. E.g. to replace pluginfile.php links with local files.
+*/
+import (
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"net/url"
+	"path"
+	"scar/progress"
+	"strings"
+)
+
+// linkAttributes contains for every tag the attributes which can contain a link
+var linkAttributes = map[string][]string{
+	"a":      {"href"},
+	"img":    {"src"},
+	"source": {"src"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"track":  {"src"},
+	"iframe": {"src"},
+	"embed":  {"src"},
+	"object": {"data"},
+	"link":   {"href"},
+}
+
+// rewriteHtmlLinks calls rewrite for every link in the html and replaces the link with the returned value.
+// If no link changed the content is returned unchanged
+func rewriteHtmlLinks(content string, rewrite func(link string) string) (string, error) {
+	if strings.TrimSpace(content) == "" {
+		return content, nil
+	}
+	// the content is parsed as fragment of a body. Parsing it as document would move tags like <style> or <title>
+	// into the head and lose them when only the body is written again
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return content, err
+	}
+	for _, node := range nodes {
+		body.AppendChild(node)
+	}
+	doc := goquery.NewDocumentFromNode(body)
+	changed := false
+	for tag, attributes := range linkAttributes {
+		doc.Find(tag).Each(func(_ int, selection *goquery.Selection) {
+			for _, attribute := range attributes {
+				link, ok := selection.Attr(attribute)
+				if !ok {
+					continue
+				}
+				newLink := rewrite(link)
+				if newLink != link {
+					selection.SetAttr(attribute, newLink)
+					changed = true
+				}
+			}
+		})
+	}
+	if !changed {
+		return content, nil
+	}
+	var result strings.Builder
+	for _, node := range nodes {
+		if err := html.Render(&result, node); err != nil {
+			return content, err
+		}
+	}
+	return result.String(), nil
+}
+
+func isPluginFileLink(link string) bool {
+	return strings.Contains(link, "pluginfile.php") || strings.HasPrefix(link, "@@PLUGINFILE@@")
+}
+
+// matchPluginFile searches the file of a pluginfile link. The keys of files are the paths of the files inside
+// their file area (filepath + filename). The longest path which is a suffix of the link wins
+func matchPluginFile(link string, files map[string]string) (string, bool) {
+	linkPath := link
+	if index := strings.IndexAny(linkPath, "?#"); index != -1 {
+		linkPath = linkPath[:index]
+	}
+	if unescaped, err := url.PathUnescape(linkPath); err == nil {
+		linkPath = unescaped
+	}
+	linkPath = strings.TrimPrefix(linkPath, "@@PLUGINFILE@@")
+	var match string
+	var found bool
+	var matchLength int
+	for filePath, localPath := range files {
+		if strings.HasSuffix(linkPath, filePath) && len(filePath) > matchLength {
+			match = localPath
+			matchLength = len(filePath)
+			found = true
+		}
+	}
+	return match, found
+}
+
+// localLink converts a relative file path into a link which can be used inside html
+func localLink(relPath string) string {
+	segments := strings.Split(relPath, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+// webserviceFileURL converts a pluginfile url into one which accepts the token
+func webserviceFileURL(fileURL string) string {
+	if strings.Contains(fileURL, "/webservice/pluginfile.php") {
+		return fileURL
+	}
+	return strings.Replace(fileURL, "/pluginfile.php", "/webservice/pluginfile.php", 1)
+}
+
+// fileAreaPath returns the path of a file inside its file area which is used to match pluginfile links
+func fileAreaPath(filePath string, fileName string) string {
+	return path.Join("/", filePath, fileName)
+}
+
+// isRelativeLink returns true for links which point to a local file relative to the current document
+func isRelativeLink(link string) bool {
+	if link == "" || strings.HasPrefix(link, "#") || strings.HasPrefix(link, "/") {
+		return false
+	}
+	parsed, err := url.Parse(link)
+	return err == nil && parsed.Scheme == ""
+}
+
+// prefixRelativeLinks prefixes every relative link with prefix. Used to make links relative to a module folder work from the html pages
+func prefixRelativeLinks(content string, prefix string) string {
+	result, err := rewriteHtmlLinks(content, func(link string) string {
+		if !isRelativeLink(link) {
+			return link
+		}
+		return path.Join(prefix, link)
+	})
+	if err != nil {
+		return content
+	}
+	return result
+}
+
+// MoodleInlineFile is a file which is embedded into a html text. E.g. an image in a forum post
+type MoodleInlineFile struct {
+	FileName     string `json:"filename"`
+	FilePath     string `json:"filepath"`
+	FileSize     int64  `json:"filesize"`
+	FileURL      string `json:"fileurl"`
+	TimeModified int64  `json:"timemodified"`
+}
+
+// downloadInlineFiles downloads the inline files of a html text into modulePath/relDir and replaces their links.
+// The returned html contains links relative to the module folder
+func (courseApi *CourseApi) downloadInlineFiles(content string, inlineFiles []MoodleInlineFile, modulePath string, relDir string, manifest *ModuleManifest, reporter progress.Reporter) (string, error) {
+	files := map[string]string{}
+	for _, inlineFile := range inlineFiles {
+		relPath := path.Join(relDir, path.Clean("/"+inlineFile.FilePath), inlineFile.FileName)
+		file := MoodleFile{FileName: inlineFile.FileName, FileSize: inlineFile.FileSize, FileURL: webserviceFileURL(inlineFile.FileURL), TimeModified: inlineFile.TimeModified}
+		if err := courseApi.downloadModuleFile(file, modulePath, relPath, manifest, reporter); err != nil {
+			return content, err
+		}
+		files[fileAreaPath(inlineFile.FilePath, inlineFile.FileName)] = relPath
+	}
+	result, err := rewriteHtmlLinks(content, func(link string) string {
+		if !isPluginFileLink(link) {
+			return link
+		}
+		if localPath, ok := matchPluginFile(link, files); ok {
+			return localLink(localPath)
+		}
+		return link
+	})
+	if err != nil {
+		reporter.Warning("Could not localize the inline files: " + err.Error())
+		return content, nil
+	}
+	return result, nil
+}
+
+// localizePluginFiles downloads every pluginfile link of the html into modulePath/relDir and replaces the links with
+// the local files. The returned html contains links relative to the module folder.
+// Links which could not be downloaded are kept and reported as warning
+func (courseApi *CourseApi) localizePluginFiles(content string, modulePath string, relDir string, manifest *ModuleManifest, reporter progress.Reporter) string {
+	result, err := rewriteHtmlLinks(content, func(link string) string {
+		if !strings.Contains(link, "pluginfile.php/") {
+			return link
+		}
+		relPath, fileURL, ok := pluginFileLocation(link, relDir)
+		if !ok {
+			return link
+		}
+		file := MoodleFile{FileName: path.Base(relPath), FileSize: unknownFileSize, FileURL: fileURL}
+		if err := courseApi.downloadModuleFile(file, modulePath, relPath, manifest, reporter); err != nil {
+			reporter.Warning(fmt.Sprintf("Could not download %s: %s", link, err))
+			return link
+		}
+		return localLink(relPath)
+	})
+	if err != nil {
+		reporter.Warning("Could not parse html: " + err.Error())
+		return content
+	}
+	return result
+}
+
+// pluginFileLocation returns the local path inside relDir and the download url for a pluginfile link.
+// The local path is the path after pluginfile.php (contextid/component/filearea/itemid/filepath/filename)
+func pluginFileLocation(link string, relDir string) (string, string, bool) {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Scheme == "" {
+		return "", "", false
+	}
+	index := strings.Index(parsed.Path, "pluginfile.php/")
+	if index == -1 {
+		return "", "", false
+	}
+	filePath := path.Clean("/" + parsed.Path[index+len("pluginfile.php/"):])
+	if filePath == "/" {
+		return "", "", false
+	}
+	query := parsed.Query()
+	query.Del("token")
+	parsed.RawQuery = query.Encode()
+	parsed.Fragment = ""
+	return path.Join(relDir, filePath), webserviceFileURL(parsed.String()), true
+}
@@ -0,0 +1,134 @@
+package moodle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewriteHtmlLinks(t *testing.T) {
+	toLocal := func(link string) string {
+		return strings.Replace(link, "https://moodle.example/pluginfile.php/", "files/", 1)
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "", ""},
+		{"no links", "<p>Hello</p>", "<p>Hello</p>"},
+		{"unchanged link", `<p><a href="https://example.com">x</a></p>`, `<p><a href="https://example.com">x</a></p>`},
+		{"image", `<p><img src="https://moodle.example/pluginfile.php/1/a.png"></p>`, `<p><img src="files/1/a.png"/></p>`},
+		{"several tags", `<a href="https://moodle.example/pluginfile.php/1/a.pdf">a</a><video src="https://moodle.example/pluginfile.php/1/b.mp4" poster="https://moodle.example/pluginfile.php/1/c.png"></video>`,
+			`<a href="files/1/a.pdf">a</a><video src="files/1/b.mp4" poster="files/1/c.png"></video>`},
+		{"keeps style and title", `<style>p { color: red; }</style><title>T</title><link href="https://moodle.example/pluginfile.php/1/s.css"><p>x</p>`,
+			`<style>p { color: red; }</style><title>T</title><link href="files/1/s.css"/><p>x</p>`},
+		{"text without tags", `text <img src="https://moodle.example/pluginfile.php/1/a.png"> more`, `text <img src="files/1/a.png"/> more`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := rewriteHtmlLinks(test.content, toLocal)
+			if err != nil {
+				t.Fatalf("rewriteHtmlLinks() error = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("rewriteHtmlLinks() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPrefixRelativeLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"relative", `<img src="inline/a.png">`, `<img src="data/1/inline/a.png"/>`},
+		{"absolute", `<img src="https://example.com/a.png">`, `<img src="https://example.com/a.png">`},
+		{"anchor", `<a href="#top">top</a>`, `<a href="#top">top</a>`},
+		{"root", `<a href="/a.html">a</a>`, `<a href="/a.html">a</a>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := prefixRelativeLinks(test.content, "data/1"); got != test.want {
+				t.Errorf("prefixRelativeLinks() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPluginFileLocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		link    string
+		relDir  string
+		ok      bool
+		relPath string
+		fileURL string
+	}{
+		{"image", "https://moodle.example/pluginfile.php/12/course/summary/0/a.png", "summary", true,
+			"summary/12/course/summary/0/a.png", "https://moodle.example/webservice/pluginfile.php/12/course/summary/0/a.png"},
+		{"webservice link with token", "https://moodle.example/webservice/pluginfile.php/12/mod_label/intro/b.png?token=secret&forcedownload=1", "inline", true,
+			"inline/12/mod_label/intro/b.png", "https://moodle.example/webservice/pluginfile.php/12/mod_label/intro/b.png?forcedownload=1"},
+		{"path traversal", "https://moodle.example/pluginfile.php/../../etc/passwd", "inline", true,
+			"inline/etc/passwd", "https://moodle.example/webservice/pluginfile.php/../../etc/passwd"},
+		{"fragment", "https://moodle.example/pluginfile.php/12/mod_page/content/c.pdf#page=2", "inline", true,
+			"inline/12/mod_page/content/c.pdf", "https://moodle.example/webservice/pluginfile.php/12/mod_page/content/c.pdf"},
+		{"relative link", "pluginfile.php/12/a.png", "inline", false, "", ""},
+		{"no file", "https://moodle.example/pluginfile.php/", "inline", false, "", ""},
+		{"no pluginfile", "https://moodle.example/mod/page/view.php?id=3", "inline", false, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			relPath, fileURL, ok := pluginFileLocation(test.link, test.relDir)
+			if ok != test.ok || relPath != test.relPath || fileURL != test.fileURL {
+				t.Errorf("pluginFileLocation() = %q, %q, %v, want %q, %q, %v", relPath, fileURL, ok, test.relPath, test.fileURL, test.ok)
+			}
+		})
+	}
+}
+
+func TestMatchPluginFile(t *testing.T) {
+	files := map[string]string{
+		"/a.png":     "inline/a.png",
+		"/dir/a.png": "inline/dir/a.png",
+		"/b c.png":   "inline/b c.png",
+	}
+	tests := []struct {
+		name  string
+		link  string
+		want  string
+		found bool
+	}{
+		{"placeholder", "@@PLUGINFILE@@/a.png", "inline/a.png", true},
+		{"longest path wins", "https://moodle.example/pluginfile.php/12/mod_forum/post/3/dir/a.png", "inline/dir/a.png", true},
+		{"escaped", "https://moodle.example/pluginfile.php/12/mod_forum/post/3/b%20c.png?forcedownload=1", "inline/b c.png", true},
+		{"unknown", "https://moodle.example/pluginfile.php/12/mod_forum/post/3/d.png", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, found := matchPluginFile(test.link, files)
+			if got != test.want || found != test.found {
+				t.Errorf("matchPluginFile() = %q, %v, want %q, %v", got, found, test.want, test.found)
+			}
+		})
+	}
+}
+
+func TestLocalLink(t *testing.T) {
+	tests := []struct {
+		relPath string
+		want    string
+	}{
+		{"inline/a.png", "inline/a.png"},
+		{"inline/b c.png", "inline/b%20c.png"},
+		{"inline/a#b?.png", "inline/a%23b%3F.png"},
+	}
+	for _, test := range tests {
+		t.Run(test.relPath, func(t *testing.T) {
+			if got := localLink(test.relPath); got != test.want {
+				t.Errorf("localLink() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
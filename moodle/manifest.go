@@ -0,0 +1,261 @@
+package moodle
+
+/**
+The manifest remembers what was downloaded for a course during the last sync. With it only new or changed modules and files
+get downloaded again and modules or files which were removed from moodle can be marked.
+*/
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"scar/progress"
+	"scar/util"
+	"sync"
+	"time"
+)
+
+const manifestFileName = "manifest.json"
+
+// unknownFileSize is used for files where moodle does not tell the size. E.g. images inside html.
+// Such files are downloaded again every time and only replaced if their content changed
+const unknownFileSize = -1
+
+type CourseManifest struct {
+	CourseID int                     `json:"courseid"`
+	LastSync int64                   `json:"lastsync"`
+	Modules  map[int]*ModuleManifest `json:"modules"`
+	// CourseImage is the url of the downloaded course image. The image is only downloaded again if the url changes
+	CourseImage string `json:"courseimage,omitempty"`
+	path        string
+	mu          sync.Mutex
+}
+
+type ModuleManifest struct {
+	CMID         int                      `json:"cmid"`
+	ID           int                      `json:"id"`
+	SectionID    int                      `json:"sectionid"`
+	Name         string                   `json:"name"`
+	ModName      string                   `json:"modname"`
+	TimeModified int64                    `json:"timemodified"`
+	Removed      bool                     `json:"removed"`
+	RemovedAt    int64                    `json:"removedat,omitempty"`
+	Files        map[string]*FileManifest `json:"files"`
+	Versions     []FileVersion            `json:"versions,omitempty"`
+	seen         bool
+	mu           sync.Mutex
+}
+
+type FileManifest struct {
+	Size         int64  `json:"size"`
+	TimeModified int64  `json:"timemodified"`
+	Hash         string `json:"hash"`
+	Removed      bool   `json:"removed"`
+	RemovedAt    int64  `json:"removedat,omitempty"`
+	seen         bool
+}
+
+// syncStats counts what changed during one sync of a course
+type syncStats struct {
+	New       int
+	Changed   int
+	Unchanged int
+	Removed   int
+	Failed    int
+}
+
+// LoadCourseManifest loads the manifest of a course. If it does not exist yet an empty one is returned
+func LoadCourseManifest(coursePath string, courseID int) *CourseManifest {
+	manifest := &CourseManifest{CourseID: courseID, Modules: map[int]*ModuleManifest{}}
+	data, err := os.ReadFile(filepath.Join(coursePath, manifestFileName))
+	if err == nil {
+		if err := json.Unmarshal(data, manifest); err != nil {
+			manifest = &CourseManifest{CourseID: courseID, Modules: map[int]*ModuleManifest{}}
+		}
+	}
+	if manifest.Modules == nil {
+		manifest.Modules = map[int]*ModuleManifest{}
+	}
+	manifest.path = filepath.Join(coursePath, manifestFileName)
+	return manifest
+}
+
+func (cm *CourseManifest) save() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.LastSync = time.Now().Unix()
+	return util.SaveStructToJSON(cm, cm.path)
+}
+
+// module returns the manifest entry of a module and marks it as seen in this sync. The bool is true if the module is new
+func (cm *CourseManifest) module(module *CourseModule, sectionID int) (*ModuleManifest, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	mm, ok := cm.Modules[module.ComponentID]
+	if !ok {
+		mm = &ModuleManifest{CMID: module.ComponentID, Files: map[string]*FileManifest{}}
+		cm.Modules[module.ComponentID] = mm
+	}
+	if mm.Files == nil {
+		mm.Files = map[string]*FileManifest{}
+	}
+	mm.ID = module.ID
+	mm.SectionID = sectionID
+	mm.Name = module.Name
+	mm.ModName = module.ModName
+	mm.Removed = false
+	mm.RemovedAt = 0
+	mm.seen = true
+	return mm, !ok
+}
+
+// markRemovedModules marks every module which was not seen in this sync as removed and returns the newly removed ones
+func (cm *CourseManifest) markRemovedModules() []*ModuleManifest {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	var removed []*ModuleManifest
+	for _, mm := range cm.Modules {
+		if mm.seen || mm.Removed {
+			continue
+		}
+		mm.Removed = true
+		mm.RemovedAt = time.Now().Unix()
+		removed = append(removed, mm)
+	}
+	return removed
+}
+
+// IsRemoved returns true if the module with the given cmid does not exist on moodle anymore
+func (cm *CourseManifest) IsRemoved(cmid int) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	mm, ok := cm.Modules[cmid]
+	return ok && mm.Removed
+}
+
+// isUnchanged checks if the file was already downloaded with the same timemodified and size
+func (mm *ModuleManifest) isUnchanged(relPath string, file MoodleFile, path string) bool {
+	if file.FileSize == unknownFileSize {
+		return false
+	}
+	fileInfo, err := os.Stat(path)
+	if err != nil || fileInfo.Size() != file.FileSize {
+		return false
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	fm, ok := mm.Files[relPath]
+	if !ok {
+		// downloaded before the manifest existed. Trust the size like before and remember the file
+		hash, err := hashFile(path)
+		if err != nil {
+			return false
+		}
+		mm.Files[relPath] = &FileManifest{Size: file.FileSize, TimeModified: file.TimeModified, Hash: hash, seen: true}
+		return true
+	}
+	if fm.Size != file.FileSize || fm.TimeModified != file.TimeModified {
+		return false
+	}
+	fm.seen = true
+	fm.Removed = false
+	fm.RemovedAt = 0
+	return true
+}
+
+// sameContent checks if the file at path already has the downloaded content. This is the case for files with an unknown
+// size which did not change or files where only the timemodified changed. The file is remembered as unchanged then
+func (mm *ModuleManifest) sameContent(relPath string, file MoodleFile, path string, hash string) bool {
+	localHash, err := hashFile(path)
+	if err != nil || localHash != hash {
+		return false
+	}
+	mm.recordFile(relPath, file, hash)
+	return true
+}
+
+// recordFile saves a downloaded file and returns true if the file was new
+func (mm *ModuleManifest) recordFile(relPath string, file MoodleFile, hash string) bool {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	_, ok := mm.Files[relPath]
+	mm.Files[relPath] = &FileManifest{Size: file.FileSize, TimeModified: file.TimeModified, Hash: hash, seen: true}
+	return !ok
+}
+
+// markRemovedFiles marks every file which was not seen while downloading the module as removed
+func (mm *ModuleManifest) markRemovedFiles() []string {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	var removed []string
+	for relPath, fm := range mm.Files {
+		if fm.seen || fm.Removed {
+			continue
+		}
+		fm.Removed = true
+		fm.RemovedAt = time.Now().Unix()
+		removed = append(removed, relPath)
+	}
+	return removed
+}
+
+// downloadModuleFile downloads a file of a module into modulePath/relPath if it is new or changed since the last sync.
+// manifest can be nil. Then only the file size is compared
+func (courseApi *CourseApi) downloadModuleFile(file MoodleFile, modulePath string, relPath string, manifest *ModuleManifest, reporter progress.Reporter) error {
+	path := filepath.Join(modulePath, relPath)
+	if manifest == nil {
+		return courseApi.client.downloadFile(file.FileURL, path, file.FileSize, reporter)
+	}
+	if manifest.isUnchanged(relPath, file, path) {
+		logrus.Info("Skip file download ", path)
+		return nil
+	}
+	tmpPath, hash, err := courseApi.client.fetchTempFile(file.FileURL, path, reporter)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	if manifest.sameContent(relPath, file, path, hash) {
+		logrus.Info("File did not change ", path)
+		return nil
+	}
+	var version *FileVersion
+	if keepVersions() {
+		version, err = manifest.archiveFile(modulePath, relPath)
+		if err != nil {
+			reporter.Warning("Could not keep old version of " + relPath + ": " + err.Error())
+		}
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		if version != nil {
+			_ = os.Rename(filepath.Join(modulePath, version.Path), path)
+		}
+		return err
+	}
+	if version != nil {
+		manifest.keepVersion(modulePath, version, hash)
+	}
+	if manifest.recordFile(relPath, file, hash) {
+		reporter.Info(fmt.Sprintf("New file: %s", relPath))
+	} else {
+		reporter.Info(fmt.Sprintf("Changed file: %s", relPath))
+	}
+	return nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,162 @@
+package moodle
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModuleManifestIsUnchanged(t *testing.T) {
+	modulePath := t.TempDir()
+	writeTestFile(t, filepath.Join(modulePath, "contents", "a.pdf"), "12345")
+	file := MoodleFile{FileName: "a.pdf", FileSize: 5, TimeModified: 100}
+	tests := []struct {
+		name     string
+		recorded *FileManifest
+		file     MoodleFile
+		relPath  string
+		want     bool
+	}{
+		{"same size and time", &FileManifest{Size: 5, TimeModified: 100}, file, "contents/a.pdf", true},
+		{"changed time", &FileManifest{Size: 5, TimeModified: 99}, file, "contents/a.pdf", false},
+		{"changed size on moodle", &FileManifest{Size: 5, TimeModified: 100}, MoodleFile{FileSize: 6, TimeModified: 100}, "contents/a.pdf", false},
+		{"truncated local file", &FileManifest{Size: 6, TimeModified: 100}, MoodleFile{FileSize: 6, TimeModified: 100}, "contents/a.pdf", false},
+		{"missing local file", &FileManifest{Size: 5, TimeModified: 100}, file, "contents/b.pdf", false},
+		{"downloaded before the manifest", nil, file, "contents/a.pdf", true},
+		{"unknown size", &FileManifest{Size: unknownFileSize}, MoodleFile{FileSize: unknownFileSize}, "contents/a.pdf", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifest := &ModuleManifest{Files: map[string]*FileManifest{}}
+			if test.recorded != nil {
+				manifest.Files[test.relPath] = test.recorded
+			}
+			got := manifest.isUnchanged(test.relPath, test.file, filepath.Join(modulePath, test.relPath))
+			if got != test.want {
+				t.Errorf("isUnchanged() = %v, want %v", got, test.want)
+			}
+			if got && (manifest.Files[test.relPath] == nil || !manifest.Files[test.relPath].seen) {
+				t.Errorf("an unchanged file has to be marked as seen")
+			}
+		})
+	}
+}
+
+func TestModuleManifestSameContent(t *testing.T) {
+	modulePath := t.TempDir()
+	path := filepath.Join(modulePath, "inline", "a.png")
+	writeTestFile(t, path, "image")
+	hash, err := hashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := MoodleFile{FileName: "a.png", FileSize: unknownFileSize}
+	tests := []struct {
+		name string
+		path string
+		hash string
+		want bool
+	}{
+		{"same content", path, hash, true},
+		{"changed content", path, "other", false},
+		{"missing file", filepath.Join(modulePath, "inline", "b.png"), hash, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifest := &ModuleManifest{Files: map[string]*FileManifest{}}
+			if got := manifest.sameContent("inline/a.png", file, test.path, test.hash); got != test.want {
+				t.Errorf("sameContent() = %v, want %v", got, test.want)
+			}
+			if fm, ok := manifest.Files["inline/a.png"]; ok != test.want || (ok && (fm.Hash != test.hash || !fm.seen)) {
+				t.Errorf("sameContent() recorded %+v", fm)
+			}
+		})
+	}
+}
+
+func TestModuleManifestMarkRemovedFiles(t *testing.T) {
+	manifest := &ModuleManifest{Files: map[string]*FileManifest{
+		"seen":            {seen: true},
+		"removed":         {},
+		"already removed": {Removed: true, RemovedAt: 1},
+	}}
+	if isNew := manifest.recordFile("new", MoodleFile{FileSize: 1}, "hash"); !isNew {
+		t.Errorf("recordFile() = false for a new file")
+	}
+	if isNew := manifest.recordFile("seen", MoodleFile{FileSize: 1}, "hash"); isNew {
+		t.Errorf("recordFile() = true for a known file")
+	}
+	removed := manifest.markRemovedFiles()
+	if len(removed) != 1 || removed[0] != "removed" {
+		t.Errorf("markRemovedFiles() = %v, want [removed]", removed)
+	}
+	if !manifest.Files["removed"].Removed || manifest.Files["removed"].RemovedAt == 0 {
+		t.Errorf("removed file is not marked: %+v", manifest.Files["removed"])
+	}
+	if manifest.Files["already removed"].RemovedAt != 1 {
+		t.Errorf("the removal time of an already removed file changed")
+	}
+}
+
+func TestCourseManifestModules(t *testing.T) {
+	manifest := LoadCourseManifest(t.TempDir(), 1)
+	manifest.Modules[5] = &ModuleManifest{CMID: 5, Name: "old"}
+	manifest.Modules[6] = &ModuleManifest{CMID: 6, Name: "gone"}
+	manifest.Modules[7] = &ModuleManifest{CMID: 7, Name: "removed earlier", Removed: true}
+
+	if _, isNew := manifest.module(&CourseModule{ID: 50, ComponentID: 5, Name: "renamed", ModName: "page"}, 2); isNew {
+		t.Errorf("module() reports a known module as new")
+	}
+	if _, isNew := manifest.module(&CourseModule{ID: 80, ComponentID: 8, Name: "new", ModName: "label"}, 2); !isNew {
+		t.Errorf("module() reports a new module as known")
+	}
+	if manifest.Modules[5].Name != "renamed" || manifest.Modules[5].ID != 50 || manifest.Modules[5].SectionID != 2 {
+		t.Errorf("module() did not update the module: %+v", manifest.Modules[5])
+	}
+
+	var removed []string
+	for _, mm := range manifest.markRemovedModules() {
+		removed = append(removed, mm.Name)
+	}
+	sort.Strings(removed)
+	if len(removed) != 1 || removed[0] != "gone" {
+		t.Errorf("markRemovedModules() = %v, want [gone]", removed)
+	}
+	if !manifest.IsRemoved(6) || !manifest.IsRemoved(7) || manifest.IsRemoved(5) || manifest.IsRemoved(9) {
+		t.Errorf("IsRemoved() does not match the removed modules")
+	}
+}
+
+func TestCourseManifestSaveAndLoad(t *testing.T) {
+	coursePath := t.TempDir()
+	manifest := LoadCourseManifest(coursePath, 1)
+	mm, _ := manifest.module(&CourseModule{ID: 50, ComponentID: 5, Name: "page", ModName: "page"}, 2)
+	mm.recordFile("content/index.html", MoodleFile{FileSize: 10, TimeModified: 100}, "hash")
+	if err := manifest.save(); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	loaded := LoadCourseManifest(coursePath, 1)
+	if loaded.LastSync == 0 {
+		t.Errorf("LastSync was not saved")
+	}
+	fm := loaded.Modules[5].Files["content/index.html"]
+	if fm == nil || fm.Size != 10 || fm.TimeModified != 100 || fm.Hash != "hash" {
+		t.Errorf("loaded file = %+v", fm)
+	}
+
+	writeTestFile(t, filepath.Join(coursePath, manifestFileName), "not json")
+	if broken := LoadCourseManifest(coursePath, 1); broken.Modules == nil || len(broken.Modules) != 0 {
+		t.Errorf("a broken manifest has to load as empty manifest")
+	}
+}
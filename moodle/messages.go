@@ -0,0 +1,229 @@
+package moodle
+
+/**
+Archives the conversations of the user. All conversations are stored in messages/data.json. On every sync only messages
+which are newer than the last archived message of a conversation are requested.
+*/
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"scar/progress"
+	"scar/util"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// messagesItemID is the id of the item which archives the conversations of the user instead of a course
+const messagesItemID = "messages"
+const messagesFolderName = "messages"
+const messagesPerRequest = 100
+
+type ConversationsResponse struct {
+	Conversations []Conversation `json:"conversations"`
+	Error         string         `json:"error"`
+}
+
+type Conversation struct {
+	ID      int                  `json:"id"`
+	Name    string               `json:"name"`
+	Type    int                  `json:"type"`
+	Members []ConversationMember `json:"members"`
+}
+
+type ConversationMember struct {
+	ID       int    `json:"id"`
+	FullName string `json:"fullname"`
+}
+
+type ConversationMessagesResponse struct {
+	Members  []ConversationMember  `json:"members"`
+	Messages []ConversationMessage `json:"messages"`
+	Error    string                `json:"error"`
+}
+
+type ConversationMessage struct {
+	ID          int    `json:"id"`
+	UserIDFrom  int    `json:"useridfrom"`
+	Text        string `json:"text"`
+	TimeCreated int64  `json:"timecreated"`
+}
+
+type DownloadMessagesData struct {
+	UserID        int                    `json:"userid"`
+	Conversations []DownloadConversation `json:"conversations"`
+}
+
+type DownloadConversation struct {
+	ID      int                  `json:"id"`
+	Name    string               `json:"name"`
+	Type    int                  `json:"type"`
+	Members []ConversationMember `json:"members"`
+	// Messages are sorted from the oldest to the newest. Links to images are relative to the messages folder
+	Messages []ConversationMessage `json:"messages"`
+}
+
+func (courseApi *CourseApi) getConversations(userID int) ([]Conversation, error) {
+	body, err := courseApi.client.makeWebserviceRequest("core_message_get_conversations", map[string]string{
+		"userid":    strconv.Itoa(userID),
+		"mergeself": "1",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response ConversationsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%v", response.Error)
+	}
+	return response.Conversations, nil
+}
+
+// getConversationMessages requests all messages of the conversation which were sent after timeFrom
+func (courseApi *CourseApi) getConversationMessages(userID int, conversationID int, timeFrom int64) (*ConversationMessagesResponse, error) {
+	result := &ConversationMessagesResponse{}
+	for from := 0; ; from += messagesPerRequest {
+		body, err := courseApi.client.makeWebserviceRequest("core_message_get_conversation_messages", map[string]string{
+			"currentuserid": strconv.Itoa(userID),
+			"convid":        strconv.Itoa(conversationID),
+			"limitfrom":     strconv.Itoa(from),
+			"limitnum":      strconv.Itoa(messagesPerRequest),
+			"newest":        "0",
+			"timefrom":      strconv.FormatInt(timeFrom, 10),
+		})
+		if err != nil {
+			return nil, err
+		}
+		var response ConversationMessagesResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		if response.Error != "" {
+			return nil, fmt.Errorf("%v", response.Error)
+		}
+		result.Members = append(result.Members, response.Members...)
+		result.Messages = append(result.Messages, response.Messages...)
+		if len(response.Messages) < messagesPerRequest {
+			return result, nil
+		}
+	}
+}
+
+// DownloadMessages archives all conversations of the user into basePath/messages
+func (courseApi *CourseApi) DownloadMessages(basePath string, reporter progress.Reporter) error {
+	userID, err := courseApi.client.getUserID()
+	if err != nil {
+		return err
+	}
+	conversations, err := courseApi.getConversations(userID)
+	if err != nil {
+		return err
+	}
+	messagesPath := filepath.Join(basePath, messagesFolderName)
+	archived := loadConversations(messagesPath)
+
+	data := DownloadMessagesData{UserID: userID}
+	reporter.Started("Messages", len(conversations))
+	for _, conversation := range conversations {
+		downloadConversation := archived[conversation.ID]
+		delete(archived, conversation.ID)
+		downloadConversation.ID = conversation.ID
+		downloadConversation.Type = conversation.Type
+		response, err := courseApi.getConversationMessages(userID, conversation.ID, lastMessageTime(downloadConversation))
+		if err != nil {
+			reporter.Failed(conversationName(conversation.Name, conversation.Members, userID), err)
+			data.Conversations = append(data.Conversations, downloadConversation)
+			continue
+		}
+		downloadConversation.Members = mergeMembers(downloadConversation.Members, append(conversation.Members, response.Members...))
+		downloadConversation.Name = conversationName(conversation.Name, downloadConversation.Members, userID)
+		known := map[int]bool{}
+		for _, message := range downloadConversation.Messages {
+			known[message.ID] = true
+		}
+		for _, message := range response.Messages {
+			if known[message.ID] {
+				continue
+			}
+			message.Text = courseApi.localizePluginFiles(message.Text, messagesPath, "files", nil, reporter)
+			downloadConversation.Messages = append(downloadConversation.Messages, message)
+		}
+		sort.SliceStable(downloadConversation.Messages, func(i, j int) bool {
+			return downloadConversation.Messages[i].TimeCreated < downloadConversation.Messages[j].TimeCreated
+		})
+		data.Conversations = append(data.Conversations, downloadConversation)
+		reporter.ItemDone(downloadConversation.Name)
+	}
+	// conversations which were deleted on moodle are kept
+	for _, conversation := range archived {
+		data.Conversations = append(data.Conversations, conversation)
+	}
+	sort.SliceStable(data.Conversations, func(i, j int) bool {
+		return lastMessageTime(data.Conversations[i]) > lastMessageTime(data.Conversations[j])
+	})
+
+	if err := util.SaveStructToJSON(data, filepath.Join(messagesPath, "data.json")); err != nil {
+		return err
+	}
+	reporter.Finished("Messages")
+	return nil
+}
+
+func lastMessageTime(conversation DownloadConversation) int64 {
+	if len(conversation.Messages) == 0 {
+		return 0
+	}
+	return conversation.Messages[len(conversation.Messages)-1].TimeCreated
+}
+
+func loadConversations(messagesPath string) map[int]DownloadConversation {
+	conversations := map[int]DownloadConversation{}
+	content, err := os.ReadFile(filepath.Join(messagesPath, "data.json"))
+	if err != nil {
+		return conversations
+	}
+	var data DownloadMessagesData
+	if err := json.Unmarshal(content, &data); err != nil {
+		return conversations
+	}
+	for _, conversation := range data.Conversations {
+		conversations[conversation.ID] = conversation
+	}
+	return conversations
+}
+
+// mergeMembers adds the new members to the known members. A member is only added once
+func mergeMembers(members []ConversationMember, newMembers []ConversationMember) []ConversationMember {
+	known := map[int]bool{}
+	for _, member := range members {
+		known[member.ID] = true
+	}
+	for _, member := range newMembers {
+		if !known[member.ID] {
+			members = append(members, member)
+			known[member.ID] = true
+		}
+	}
+	return members
+}
+
+// conversationName returns the name of group conversations or the names of the other members for private conversations
+func conversationName(name string, members []ConversationMember, userID int) string {
+	if name != "" {
+		return name
+	}
+	var names []string
+	for _, member := range members {
+		if member.ID != userID {
+			names = append(names, member.FullName)
+		}
+	}
+	if len(names) == 0 {
+		return "Personal space"
+	}
+	return strings.Join(names, ", ")
+}
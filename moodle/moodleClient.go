@@ -1,7 +1,8 @@
 package moodle
 
 import (
-	"crypto/tls"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/sirupsen/logrus"
@@ -10,6 +11,9 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"scar/httpclient"
+	"scar/progress"
+	"sync"
 )
 
 type TokenResponse struct {
@@ -24,9 +28,13 @@ type MoodleClient struct {
 	Token        string
 	PrivateToken string
 	Username     string
+	UserID       int
 	SkipSSL      bool
 	CourseApi    *CourseApi
-	Client       *http.Client
+	Client       *httpclient.Client
+	// fileSlots limits how many files are downloaded at the same time
+	fileSlots chan struct{}
+	userMu    sync.Mutex
 }
 
 func NewMoodleClient(skipSSL bool) *MoodleClient {
@@ -35,14 +43,26 @@ func NewMoodleClient(skipSSL bool) *MoodleClient {
 	}
 	client := &MoodleClient{SkipSSL: skipSSL}
 	client.CourseApi = newCourseApi(client)
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipSSL},
-	}
-
-	client.Client = &http.Client{Transport: tr}
+	client.Configure(defaultFileWorkers, httpclient.DefaultOptions())
 
 	return client
 }
+
+// Configure sets how many files are downloaded at the same time and the options of the http client.
+// SSL verification is skipped if the client was created with skipSSL
+func (mc *MoodleClient) Configure(fileWorkers int, options httpclient.Options) {
+	if fileWorkers < 1 {
+		fileWorkers = 1
+	}
+	mc.fileSlots = make(chan struct{}, fileWorkers)
+	options.SkipSSL = mc.SkipSSL
+	mc.Client = httpclient.New(options)
+}
+
+// fileWorkers returns how many files can be downloaded at the same time
+func (mc *MoodleClient) fileWorkers() int {
+	return cap(mc.fileSlots)
+}
 func (mc *MoodleClient) Login(username string, password string) error {
 	loginURL := fmt.Sprintf("%s/login/token.php", mc.ServiceUrl)
 	data := url.Values{}
@@ -124,22 +144,71 @@ func (mc *MoodleClient) makeModRequest(function string, params map[string]string
 	return mc.makeRequest(function, params, "/mod/assign/view.php")
 }
 
-func (mc *MoodleClient) DownloadFile(url string, path string, filesize int64) error {
-	return mc.downloadFile(url, path, filesize)
+// getUserID returns the id of the logged in user. It is requested once from the site info
+func (mc *MoodleClient) getUserID() (int, error) {
+	mc.userMu.Lock()
+	defer mc.userMu.Unlock()
+	if mc.UserID != 0 {
+		return mc.UserID, nil
+	}
+	body, err := mc.makeWebserviceRequest("core_webservice_get_site_info", map[string]string{})
+	if err != nil {
+		return 0, err
+	}
+	var siteInfo struct {
+		UserID int    `json:"userid"`
+		Error  string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &siteInfo); err != nil {
+		return 0, err
+	}
+	if siteInfo.Error != "" {
+		return 0, fmt.Errorf("%v", siteInfo.Error)
+	}
+	mc.UserID = siteInfo.UserID
+	return mc.UserID, nil
+}
+
+func (mc *MoodleClient) DownloadFile(url string, path string, filesize int64, reporter progress.Reporter) error {
+	return mc.downloadFile(url, path, filesize, reporter)
 }
 
-func (mc *MoodleClient) downloadFile(url string, path string, filesize int64) error {
+func (mc *MoodleClient) downloadFile(url string, path string, filesize int64, reporter progress.Reporter) error {
 
 	fileInfo, err := os.Stat(path)
 	if err == nil {
-		if filesize == fileInfo.Size() {
+		// files with an unknown size can change without a visible difference, so they are downloaded again
+		if filesize != unknownFileSize && filesize == fileInfo.Size() {
 			logrus.Info("Skip file download ", path)
 			return nil
 		}
 	}
+	_, err = mc.fetchFile(url, path, reporter)
+	return err
+}
+
+// fetchFile downloads the file without checking if it already exists and returns the sha256 hash of the content
+func (mc *MoodleClient) fetchFile(url string, path string, reporter progress.Reporter) (string, error) {
+	tmpPath, hash, err := mc.fetchTempFile(url, path, reporter)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", err
+	}
+	return hash, nil
+}
+
+// fetchTempFile downloads the file into a temporary file next to path and returns the temporary file and the sha256
+// hash of the content. The download never goes directly to path, so an interrupted download does not leave a
+// truncated file behind. The caller has to rename or remove the temporary file
+func (mc *MoodleClient) fetchTempFile(url string, path string, reporter progress.Reporter) (string, string, error) {
+	mc.fileSlots <- struct{}{}
+	defer func() { <-mc.fileSlots }()
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
 	q := req.URL.Query()
@@ -148,27 +217,34 @@ func (mc *MoodleClient) downloadFile(url string, path string, filesize int64) er
 
 	resp, err := mc.Client.Do(req)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download file: status code %d", resp.StatusCode)
+		return "", "", fmt.Errorf("failed to download file: status code %d", resp.StatusCode)
 	}
 
 	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
-		return err
+		return "", "", err
 	}
-	outFile, err := os.Create(path)
+	outFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.part")
 	if err != nil {
-		return err
+		return "", "", err
 	}
-	defer outFile.Close()
+	// CreateTemp only allows the owner to read the file, os.Create allowed everyone before
+	_ = outFile.Chmod(0644)
 
-	_, err = io.Copy(outFile, resp.Body)
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(outFile, hash), resp.Body)
+	reporter.BytesTransferred(written)
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
-		return err
+		_ = os.Remove(outFile.Name())
+		return "", "", err
 	}
 
-	return nil
+	return outFile.Name(), hex.EncodeToString(hash.Sum(nil)), nil
 }
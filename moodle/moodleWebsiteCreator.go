@@ -7,22 +7,39 @@ import (
 	"github.com/sirupsen/logrus"
 	"html/template"
 	"log"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"scar/util"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 type coursesOverviewPage struct {
 	Courses []courseData
 }
 type courseModule struct {
-	ID      int
+	ID int
+	// PageID is the name of the html page of the module. It is the cmid because the instance id is only unique per
+	// module type. Older archives without a cmid use the instance id
+	PageID  int
 	Name    string
 	ModName string
 	Data    map[string]interface{}
 	Path    string
+	Removed bool
+	Dates   []moduleDate
+	// SubmissionStatus is only set for assignments
+	SubmissionStatus string
+}
+
+// moduleDate is a formatted date of a module. E.g. the due date of an assignment
+type moduleDate struct {
+	Label string
+	Date  string
 }
 type courseSection struct {
 	Name          string `json:"name"`
@@ -51,8 +68,42 @@ type assignmentMod struct {
 	SubmissionStatement        template.HTML `json:"submissionstatement"`
 	IntroAttachments           []string      `json:"introattachmentsnames"`
 	SubmissionAttachments      []string      `json:"submissionattachmentsnames"`
+	SubmissionAttachmentsFiles []string      `json:"submissionattachmentspaths"`
 	IntroAttachmentsPaths      []string
 	SubmissionAttachmentsPaths []string
+	Grade                      template.HTML `json:"grade"`
+	GradedDate                 int64         `json:"gradeddate"`
+	FeedbackComments           string        `json:"feedbackcomments"`
+	FeedbackAttachments        []string      `json:"feedbackattachmentsnames"`
+	FeedbackAttachmentsFiles   []string      `json:"feedbackattachmentspaths"`
+	FeedbackAttachmentsPaths   []string
+	FeedbackHTML               template.HTML
+	GradedDateText             string
+	Attempts                   []assignmentAttempt `json:"attempts"`
+	Versions                   []fileVersion       `json:"versions"`
+	DueDate                    int64               `json:"duedate"`
+	CutoffDate                 int64               `json:"cutoffdate"`
+	AllowSubmissionsFromDate   int64               `json:"allowsubmissionsfromdate"`
+	ExtensionDueDate           int64               `json:"extensionduedate"`
+	SubmissionStatus           string              `json:"submissionstatus"`
+	TimeSubmitted              int64               `json:"timesubmitted"`
+	Dates                      []moduleDate
+}
+type assignmentAttempt struct {
+	AttemptNumber    int      `json:"attemptnumber"`
+	Status           string   `json:"status"`
+	TimeCreated      int64    `json:"timecreated"`
+	TimeModified     int64    `json:"timemodified"`
+	OnlineText       string   `json:"onlinetext"`
+	Grade            string   `json:"grade"`
+	FeedbackComments string   `json:"feedbackcomments"`
+	Files            []string `json:"files"`
+	Created          string
+	Modified         string
+	OnlineTextHTML   template.HTML
+	FeedbackHTML     template.HTML
+	GradeHTML        template.HTML
+	FileLinks        []fileLink
 }
 type labelMod struct {
 	ID          int           `json:"id"`
@@ -66,6 +117,195 @@ type resourceMod struct {
 	Name             string   `json:"name"`
 	ContentFileNames []string `json:"contentfilenames"`
 	ContentFilePath  string
+	Versions         []fileVersion `json:"versions"`
+}
+
+type folderMod struct {
+	Name        string        `json:"name"`
+	Description template.HTML `json:"description"`
+	Tree        *folderNode   `json:"tree"`
+	Versions    []fileVersion `json:"versions"`
+}
+type folderNode struct {
+	Name     string        `json:"name"`
+	Path     string        `json:"path"`
+	IsDir    bool          `json:"isdir"`
+	Size     int64         `json:"size"`
+	Children []*folderNode `json:"children"`
+}
+
+type pageMod struct {
+	Name     string        `json:"name"`
+	Content  string        `json:"content"`
+	HTML     template.HTML `json:"-"`
+	Versions []fileVersion `json:"versions"`
+}
+
+type forumMod struct {
+	Name        string            `json:"name"`
+	Description template.HTML     `json:"description"`
+	Discussions []forumDiscussion `json:"discussions"`
+}
+type forumDiscussion struct {
+	Name    string      `json:"name"`
+	Author  string      `json:"author"`
+	Created int64       `json:"created"`
+	Pinned  bool        `json:"pinned"`
+	Locked  bool        `json:"locked"`
+	Posts   []forumPost `json:"posts"`
+	Date    string
+	Thread  []*forumPost
+}
+type forumPost struct {
+	ID              int      `json:"id"`
+	ParentID        int      `json:"parentid"`
+	Subject         string   `json:"subject"`
+	Message         string   `json:"message"`
+	Author          string   `json:"author"`
+	TimeCreated     int64    `json:"timecreated"`
+	Deleted         bool     `json:"deleted"`
+	Attachments     []string `json:"attachments"`
+	HTML            template.HTML
+	Date            string
+	AttachmentLinks []fileLink
+	Replies         []*forumPost
+}
+
+type quizMod struct {
+	Name        string        `json:"name"`
+	Description template.HTML `json:"description"`
+	Attempts    []quizAttempt `json:"attempts"`
+}
+type quizAttempt struct {
+	Attempt    int            `json:"attempt"`
+	State      string         `json:"state"`
+	TimeStart  int64          `json:"timestart"`
+	TimeFinish int64          `json:"timefinish"`
+	SumGrades  *float64       `json:"sumgrades"`
+	Grade      string         `json:"grade"`
+	Feedback   []quizFeedback `json:"feedback"`
+	Questions  []quizQuestion `json:"questions"`
+	StartDate  string
+	FinishDate string
+}
+type quizFeedback struct {
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	ContentHTML template.HTML
+}
+type quizQuestion struct {
+	Number   int     `json:"number"`
+	Status   string  `json:"status"`
+	Mark     string  `json:"mark"`
+	MaxMark  float64 `json:"maxmark"`
+	HTML     string  `json:"html"`
+	Question template.HTML
+}
+
+type bookMod struct {
+	Name        string        `json:"name"`
+	Description template.HTML `json:"description"`
+	Chapters    []bookChapter `json:"chapters"`
+}
+type bookChapter struct {
+	ID          string        `json:"id"`
+	Title       string        `json:"title"`
+	Level       int           `json:"level"`
+	Hidden      bool          `json:"hidden"`
+	Content     string        `json:"content"`
+	SubChapters []bookChapter `json:"subchapters"`
+	HTML        template.HTML
+	Link        string
+	Indent      int
+}
+
+// bookPage is the data for one chapter page or the merged page of a book
+type bookPage struct {
+	Name        string
+	Description template.HTML
+	Chapters    []*bookChapter
+	Current     *bookChapter
+	Prev        *bookChapter
+	Next        *bookChapter
+	MergedLink  string
+	Merged      bool
+}
+
+type glossaryMod struct {
+	Name        string          `json:"name"`
+	Description template.HTML   `json:"description"`
+	Entries     []glossaryEntry `json:"entries"`
+	Letters     []glossaryLetter
+}
+type glossaryEntry struct {
+	Concept         string   `json:"concept"`
+	Definition      string   `json:"definition"`
+	Author          string   `json:"author"`
+	TimeModified    int64    `json:"timemodified"`
+	Aliases         []string `json:"aliases"`
+	Categories      []string `json:"categories"`
+	Attachments     []string `json:"attachments"`
+	DefinitionHTML  template.HTML
+	Date            string
+	SearchText      string
+	AttachmentLinks []fileLink
+}
+type glossaryLetter struct {
+	Letter  string
+	Entries []*glossaryEntry
+}
+
+type wikiMod struct {
+	Name        string        `json:"name"`
+	Description template.HTML `json:"description"`
+	Subwikis    []wikiSubwiki `json:"subwikis"`
+}
+type wikiSubwiki struct {
+	ID      int        `json:"id"`
+	GroupID int        `json:"groupid"`
+	UserID  int        `json:"userid"`
+	Pages   []wikiPage `json:"pages"`
+	Label   string
+}
+type wikiPage struct {
+	ID           int           `json:"id"`
+	Title        string        `json:"title"`
+	FirstPage    bool          `json:"firstpage"`
+	TimeModified int64         `json:"timemodified"`
+	Version      int           `json:"version"`
+	Content      string        `json:"content"`
+	History      []wikiVersion `json:"history"`
+	HTML         template.HTML
+	Link         string
+	Date         string
+}
+type wikiVersion struct {
+	Version      int    `json:"version"`
+	TimeModified int64  `json:"timemodified"`
+	Content      string `json:"content"`
+	HTML         template.HTML
+	Date         string
+}
+
+// wikiPageView is the data for one page of a wiki
+type wikiPageView struct {
+	Name        string
+	Description template.HTML
+	Subwikis    []wikiSubwiki
+	Current     *wikiPage
+}
+
+// fileLink is a link to a downloaded file. Path is relative to the html folder
+type fileLink struct {
+	Name string
+	Path string
+}
+
+type fileVersion struct {
+	File       string `json:"file"`
+	Path       string `json:"path"`
+	ArchivedAt int64  `json:"archivedat"`
+	Date       string
 }
 type urlMod struct {
 	Name        string   `json:"name"`
@@ -89,8 +329,23 @@ func createMoodleWebsite() error {
 		return err
 	}
 
+	if err := createGradesPage(page.Courses, moodlePath, archiverPath); err != nil {
+		logrus.Error("Could not create grades page: ", err)
+	}
+	if err := createPrivateFilesPage(moodlePath, archiverPath); err != nil {
+		logrus.Error("Could not create private files page: ", err)
+	}
+	if err := createMessagesPages(moodlePath, archiverPath); err != nil {
+		logrus.Error("Could not create messages pages: ", err)
+	}
+
+	templates, err := loadModuleTemplates()
+	if err != nil {
+		logrus.Fatal("Error loading template: ", err)
+		return err
+	}
 	for _, course := range page.Courses {
-		err := createCoursePage(course, archiverPath)
+		err := createCoursePage(course, archiverPath, templates)
 		if err != nil {
 			logrus.Error("Could not create Course page")
 			continue
@@ -126,7 +381,184 @@ func createOverviewPage(page *coursesOverviewPage, archiverPath string) error {
 	}
 	return nil
 }
-func createCoursePage(course courseData, archiverPath string) error {
+
+// gradesPage is the data for the page with the grades of all courses
+type gradesPage struct {
+	Courses []courseGradesTable
+}
+type courseGradesTable struct {
+	ID        int
+	ShortName string
+	FullName  string
+	Exported  string
+	Items     []gradeRow
+	Total     *gradeRow
+}
+type gradeRow struct {
+	Name       string
+	Type       string
+	Weight     string
+	Grade      string
+	Range      string
+	Percentage string
+	Letter     string
+	Feedback   template.HTML
+	Link       string
+}
+
+// createGradesPage creates grades.html with one table per course which has a grades.json
+func createGradesPage(courses []courseData, moodlePath string, archiverPath string) error {
+	tmpl, err := template.ParseFiles("html/moodle/templates/moodle-grades-page.html")
+	if err != nil {
+		logrus.Fatal("Error loading template: ", err)
+		return err
+	}
+	var page gradesPage
+	for _, course := range courses {
+		data, err := os.ReadFile(filepath.Join(moodlePath, strconv.Itoa(course.ID), gradesFileName))
+		if err != nil {
+			continue
+		}
+		var grades CourseGrades
+		if err := json.Unmarshal(data, &grades); err != nil {
+			logrus.Info("Could not parse grades of course ", course.ShortName, ": ", err)
+			continue
+		}
+		table := courseGradesTable{ID: course.ID, ShortName: course.ShortName, FullName: course.FullName, Exported: formatTimestamp(grades.ExportedAt)}
+		for _, item := range grades.Items {
+			row := gradeRow{
+				Name:       item.displayName(),
+				Type:       item.ItemType,
+				Weight:     item.WeightFormatted,
+				Grade:      item.GradeFormatted,
+				Range:      item.RangeFormatted,
+				Percentage: item.PercentageFormatted,
+				Letter:     item.LetterGradeFormatted,
+				Feedback:   template.HTML(item.Feedback),
+			}
+			if item.ItemType == "mod" && item.CMID != 0 {
+				row.Link = fmt.Sprintf("%d/%d.html", course.ID, item.CMID)
+			}
+			if item.ItemType == "course" {
+				total := row
+				table.Total = &total
+				continue
+			}
+			table.Items = append(table.Items, row)
+		}
+		page.Courses = append(page.Courses, table)
+	}
+	return executeTemplateToFile(tmpl, page, filepath.Join(archiverPath, "html", "moodle", "grades.html"))
+}
+
+// courseImageURL returns the url of the course image. Downloaded images are relative to the course folder and get
+// prefixed with dataPath which is the path to the moodle data folder from the html page
+func courseImageURL(course courseData, dataPath string) template.URL {
+	if !isRelativeLink(course.CourseImage) {
+		return template.URL(course.CourseImage)
+	}
+	return template.URL(path.Join(dataPath, strconv.Itoa(course.ID), localLink(course.CourseImage)))
+}
+
+// createPrivateFilesPage creates private/index.html with the tree of the downloaded private files
+func createPrivateFilesPage(moodlePath string, archiverPath string) error {
+	tmpl, err := template.ParseFiles("html/moodle/templates/course/mod/mod-folder-page.html")
+	if err != nil {
+		logrus.Fatal("Error loading template: ", err)
+		return err
+	}
+	var folder folderMod
+	folder.Name = "Private files"
+	data, err := os.ReadFile(filepath.Join(moodlePath, privateFolderName, "data.json"))
+	if err == nil {
+		if err := json.Unmarshal(data, &folder); err != nil {
+			return err
+		}
+	}
+	prepareFolderTree(folder.Tree, filepath.Join("data", privateFolderName))
+	outputPath := filepath.Join(archiverPath, "html", "moodle", privateFolderName, "index.html")
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		return err
+	}
+	return executeTemplateToFile(tmpl, folder, outputPath)
+}
+
+// messagesPage is the data for the page of one conversation
+type messagesPage struct {
+	Conversations []*conversationView
+	Current       *conversationView
+}
+type conversationView struct {
+	ID       int
+	Name     string
+	Members  string
+	Link     string
+	Messages []messageView
+}
+type messageView struct {
+	Author string
+	Date   string
+	Own    bool
+	HTML   template.HTML
+}
+
+// createMessagesPages creates one page per archived conversation. messages/index.html shows the newest conversation
+func createMessagesPages(moodlePath string, archiverPath string) error {
+	tmpl, err := template.ParseFiles("html/moodle/templates/moodle-messages-page.html")
+	if err != nil {
+		logrus.Fatal("Error loading template: ", err)
+		return err
+	}
+	var data DownloadMessagesData
+	content, err := os.ReadFile(filepath.Join(moodlePath, messagesFolderName, "data.json"))
+	if err == nil {
+		if err := json.Unmarshal(content, &data); err != nil {
+			return err
+		}
+	}
+	var page messagesPage
+	for _, conversation := range data.Conversations {
+		authors := map[int]string{}
+		var members []string
+		for _, member := range conversation.Members {
+			authors[member.ID] = member.FullName
+			members = append(members, member.FullName)
+		}
+		view := &conversationView{
+			ID:      conversation.ID,
+			Name:    conversation.Name,
+			Members: strings.Join(members, ", "),
+			Link:    fmt.Sprintf("%d.html", conversation.ID),
+		}
+		for _, message := range conversation.Messages {
+			view.Messages = append(view.Messages, messageView{
+				Author: authors[message.UserIDFrom],
+				Date:   formatTimestamp(message.TimeCreated),
+				Own:    message.UserIDFrom == data.UserID,
+				HTML:   template.HTML(prefixRelativeLinks(message.Text, "../data/"+messagesFolderName)),
+			})
+		}
+		page.Conversations = append(page.Conversations, view)
+	}
+
+	messagesHtmlPath := filepath.Join(archiverPath, "html", "moodle", messagesFolderName)
+	if err := os.MkdirAll(messagesHtmlPath, os.ModePerm); err != nil {
+		return err
+	}
+	for _, conversation := range page.Conversations {
+		page.Current = conversation
+		if err := executeTemplateToFile(tmpl, page, filepath.Join(messagesHtmlPath, conversation.Link)); err != nil {
+			return err
+		}
+	}
+	page.Current = nil
+	if len(page.Conversations) > 0 {
+		page.Current = page.Conversations[0]
+	}
+	return executeTemplateToFile(tmpl, page, filepath.Join(messagesHtmlPath, "index.html"))
+}
+
+func createCoursePage(course courseData, archiverPath string, templates map[string]*template.Template) error {
 	var outputPath = filepath.Join(archiverPath, "html", "moodle", fmt.Sprintf("%d", course.ID), "index.html")
 	tmpl, err := template.ParseFiles("html/moodle/templates/course/moodle-course-page.html")
 	if err != nil {
@@ -143,6 +575,8 @@ func createCoursePage(course courseData, archiverPath string) error {
 	}
 	defer outputFile.Close()
 
+	course.Summary = template.HTML(prefixRelativeLinks(string(course.Summary), fmt.Sprintf("../data/%d", course.ID)))
+	course.CourseImageURL = courseImageURL(course, "../data")
 	err = tmpl.Execute(outputFile, course)
 	if err != nil {
 		return err
@@ -150,7 +584,7 @@ func createCoursePage(course courseData, archiverPath string) error {
 
 	for _, section := range course.Sections {
 		for _, module := range section.CourseModules {
-			err := createModulePage(module, filepath.Dir(outputPath))
+			err := createModulePage(module, filepath.Dir(outputPath), templates)
 			if err != nil {
 				logrus.Error("Could not create Module page:", err)
 				continue
@@ -160,30 +594,39 @@ func createCoursePage(course courseData, archiverPath string) error {
 
 	return nil
 }
-func createModulePage(mod courseModule, coursePath string) error {
-	var outputPath = filepath.Join(coursePath, fmt.Sprintf("%d.html", mod.ID))
-	assignTemp, err := template.ParseFiles("html/moodle/templates/course/mod/mod-assignment-page.html")
-	if err != nil {
-		logrus.Fatal("Error loading template: ", err)
-		return err
-	}
-	labelTemp, err := template.ParseFiles("html/moodle/templates/course/mod/mod-label-page.html")
-	if err != nil {
-		logrus.Fatal("Error loading template: ", err)
-		return err
-	}
-	resourceTemp, err := template.ParseFiles("html/moodle/templates/course/mod/mod-resource-page.html")
-	if err != nil {
-		logrus.Fatal("Error loading template: ", err)
-		return err
-	}
-	urlTemp, err := template.ParseFiles("html/moodle/templates/course/mod/mod-url-page.html")
-	if err != nil {
-		logrus.Fatal("Error loading template: ", err)
-		return err
+
+// moduleTemplateFiles contains the template of every supported module type
+var moduleTemplateFiles = map[string]string{
+	"assign":   "mod-assignment-page.html",
+	"label":    "mod-label-page.html",
+	"resource": "mod-resource-page.html",
+	"url":      "mod-url-page.html",
+	"folder":   "mod-folder-page.html",
+	"page":     "mod-page-page.html",
+	"forum":    "mod-forum-page.html",
+	"quiz":     "mod-quiz-page.html",
+	"book":     "mod-book-page.html",
+	"glossary": "mod-glossary-page.html",
+	"wiki":     "mod-wiki-page.html",
+}
+
+// loadModuleTemplates parses the templates of all module types. They are parsed once for the whole site
+func loadModuleTemplates() (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+	for modName, fileName := range moduleTemplateFiles {
+		tmpl, err := template.ParseFiles(filepath.Join("html/moodle/templates/course/mod", fileName))
+		if err != nil {
+			return nil, err
+		}
+		templates[modName] = tmpl
 	}
+	return templates, nil
+}
 
-	err = os.MkdirAll(filepath.Dir(outputPath), os.ModePerm)
+func createModulePage(mod courseModule, coursePath string, templates map[string]*template.Template) error {
+	var outputPath = filepath.Join(coursePath, fmt.Sprintf("%d.html", mod.PageID))
+
+	err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -202,13 +645,44 @@ func createModulePage(mod courseModule, coursePath string) error {
 		if err := json.Unmarshal(jsonData, &assignment); err != nil {
 			return err
 		}
+		assignment.Intro = template.HTML(prefixRelativeLinks(string(assignment.Intro), "../"+mod.Path))
+		assignment.SubmissionStatement = template.HTML(prefixRelativeLinks(string(assignment.SubmissionStatement), "../"+mod.Path))
 		for _, attachment := range assignment.IntroAttachments {
 			assignment.IntroAttachmentsPaths = append(assignment.IntroAttachmentsPaths, filepath.Join(mod.Path, "introfiles", attachment))
 		}
-		for _, attachment := range assignment.SubmissionAttachments {
-			assignment.SubmissionAttachmentsPaths = append(assignment.SubmissionAttachmentsPaths, filepath.Join(mod.Path, "submissions", attachment))
+		assignment.SubmissionAttachmentsPaths = attachmentPaths(mod.Path, "submissions", assignment.SubmissionAttachments, assignment.SubmissionAttachmentsFiles)
+		assignment.FeedbackAttachmentsPaths = attachmentPaths(mod.Path, "feedback", assignment.FeedbackAttachments, assignment.FeedbackAttachmentsFiles)
+		assignment.FeedbackHTML = template.HTML(prefixRelativeLinks(assignment.FeedbackComments, "../"+mod.Path))
+		if assignment.GradedDate != 0 {
+			assignment.GradedDateText = formatTimestamp(assignment.GradedDate)
+		}
+		for _, date := range []struct {
+			label     string
+			timestamp int64
+		}{
+			{"Opened", assignment.AllowSubmissionsFromDate},
+			{"Due", assignment.DueDate},
+			{"Extended until", assignment.ExtensionDueDate},
+			{"Cut-off", assignment.CutoffDate},
+			{"Submitted", assignment.TimeSubmitted},
+		} {
+			if date.timestamp != 0 {
+				assignment.Dates = append(assignment.Dates, moduleDate{Label: date.label, Date: formatTimestamp(date.timestamp)})
+			}
+		}
+		for i := range assignment.Attempts {
+			attempt := &assignment.Attempts[i]
+			attempt.Created = formatTimestamp(attempt.TimeCreated)
+			attempt.Modified = formatTimestamp(attempt.TimeModified)
+			attempt.OnlineTextHTML = template.HTML(prefixRelativeLinks(attempt.OnlineText, "../"+mod.Path))
+			attempt.FeedbackHTML = template.HTML(prefixRelativeLinks(attempt.FeedbackComments, "../"+mod.Path))
+			attempt.GradeHTML = template.HTML(attempt.Grade)
+			for _, file := range attempt.Files {
+				attempt.FileLinks = append(attempt.FileLinks, fileLink{Name: filepath.Base(file), Path: filepath.Join(mod.Path, file)})
+			}
 		}
-		err = assignTemp.Execute(outputFile, assignment)
+		prepareVersions(assignment.Versions, mod.Path)
+		err = templates["assign"].Execute(outputFile, assignment)
 		if err != nil {
 			return err
 		}
@@ -221,7 +695,8 @@ func createModulePage(mod courseModule, coursePath string) error {
 		if err := json.Unmarshal(jsonData, &label); err != nil {
 			return err
 		}
-		err = labelTemp.Execute(outputFile, label)
+		label.Description = template.HTML(prefixRelativeLinks(string(label.Description), "../"+mod.Path))
+		err = templates["label"].Execute(outputFile, label)
 		if err != nil {
 			return err
 		}
@@ -235,7 +710,8 @@ func createModulePage(mod courseModule, coursePath string) error {
 			return err
 		}
 		resource.ContentFilePath = filepath.Join(mod.Path, "contents", resource.ContentFileNames[0])
-		err = resourceTemp.Execute(outputFile, resource)
+		prepareVersions(resource.Versions, mod.Path)
+		err = templates["resource"].Execute(outputFile, resource)
 		if err != nil {
 			return err
 		}
@@ -248,7 +724,123 @@ func createModulePage(mod courseModule, coursePath string) error {
 		if err := json.Unmarshal(jsonData, &url); err != nil {
 			return err
 		}
-		err = urlTemp.Execute(outputFile, url)
+		err = templates["url"].Execute(outputFile, url)
+		if err != nil {
+			return err
+		}
+	} else if mod.ModName == "folder" {
+		jsonData, err := json.Marshal(mod.Data)
+		if err != nil {
+			return err
+		}
+		var folder folderMod
+		if err := json.Unmarshal(jsonData, &folder); err != nil {
+			return err
+		}
+		folder.Description = template.HTML(prefixRelativeLinks(string(folder.Description), "../"+mod.Path))
+		prepareFolderTree(folder.Tree, mod.Path)
+		prepareVersions(folder.Versions, mod.Path)
+		err = templates["folder"].Execute(outputFile, folder)
+		if err != nil {
+			return err
+		}
+	} else if mod.ModName == "page" {
+		jsonData, err := json.Marshal(mod.Data)
+		if err != nil {
+			return err
+		}
+		var page pageMod
+		if err := json.Unmarshal(jsonData, &page); err != nil {
+			return err
+		}
+		page.HTML = template.HTML(prefixRelativeLinks(page.Content, "../"+mod.Path))
+		prepareVersions(page.Versions, mod.Path)
+		err = templates["page"].Execute(outputFile, page)
+		if err != nil {
+			return err
+		}
+	} else if mod.ModName == "forum" {
+		jsonData, err := json.Marshal(mod.Data)
+		if err != nil {
+			return err
+		}
+		var forum forumMod
+		if err := json.Unmarshal(jsonData, &forum); err != nil {
+			return err
+		}
+		forum.Description = template.HTML(prefixRelativeLinks(string(forum.Description), "../"+mod.Path))
+		for i := range forum.Discussions {
+			prepareDiscussion(&forum.Discussions[i], mod.Path)
+		}
+		err = templates["forum"].Execute(outputFile, forum)
+		if err != nil {
+			return err
+		}
+	} else if mod.ModName == "quiz" {
+		jsonData, err := json.Marshal(mod.Data)
+		if err != nil {
+			return err
+		}
+		var quiz quizMod
+		if err := json.Unmarshal(jsonData, &quiz); err != nil {
+			return err
+		}
+		quiz.Description = template.HTML(prefixRelativeLinks(string(quiz.Description), "../"+mod.Path))
+		for i := range quiz.Attempts {
+			attempt := &quiz.Attempts[i]
+			attempt.StartDate = formatTimestamp(attempt.TimeStart)
+			attempt.FinishDate = formatTimestamp(attempt.TimeFinish)
+			for j := range attempt.Feedback {
+				attempt.Feedback[j].ContentHTML = template.HTML(prefixRelativeLinks(attempt.Feedback[j].Content, "../"+mod.Path))
+			}
+			for j := range attempt.Questions {
+				attempt.Questions[j].Question = template.HTML(prefixRelativeLinks(attempt.Questions[j].HTML, "../"+mod.Path))
+			}
+		}
+		err = templates["quiz"].Execute(outputFile, quiz)
+		if err != nil {
+			return err
+		}
+	} else if mod.ModName == "book" {
+		jsonData, err := json.Marshal(mod.Data)
+		if err != nil {
+			return err
+		}
+		var book bookMod
+		if err := json.Unmarshal(jsonData, &book); err != nil {
+			return err
+		}
+		book.Description = template.HTML(prefixRelativeLinks(string(book.Description), "../"+mod.Path))
+		err = createBookPages(book, mod, coursePath, templates["book"], outputFile)
+		if err != nil {
+			return err
+		}
+	} else if mod.ModName == "glossary" {
+		jsonData, err := json.Marshal(mod.Data)
+		if err != nil {
+			return err
+		}
+		var glossary glossaryMod
+		if err := json.Unmarshal(jsonData, &glossary); err != nil {
+			return err
+		}
+		glossary.Description = template.HTML(prefixRelativeLinks(string(glossary.Description), "../"+mod.Path))
+		prepareGlossary(&glossary, mod.Path)
+		err = templates["glossary"].Execute(outputFile, glossary)
+		if err != nil {
+			return err
+		}
+	} else if mod.ModName == "wiki" {
+		jsonData, err := json.Marshal(mod.Data)
+		if err != nil {
+			return err
+		}
+		var wiki wikiMod
+		if err := json.Unmarshal(jsonData, &wiki); err != nil {
+			return err
+		}
+		wiki.Description = template.HTML(prefixRelativeLinks(string(wiki.Description), "../"+mod.Path))
+		err = createWikiPages(wiki, mod, coursePath, templates["wiki"], outputFile)
 		if err != nil {
 			return err
 		}
@@ -257,6 +849,231 @@ func createModulePage(mod courseModule, coursePath string) error {
 	}
 	return nil
 }
+
+// getModuleDates returns the dates which moodle shows for the module. E.g. "Opened" or "Due"
+func getModuleDates(data map[string]interface{}) []moduleDate {
+	jsonData, err := json.Marshal(data["dates"])
+	if err != nil {
+		return nil
+	}
+	var dates []CourseModuleDate
+	if err := json.Unmarshal(jsonData, &dates); err != nil {
+		return nil
+	}
+	var result []moduleDate
+	for _, date := range dates {
+		result = append(result, moduleDate{Label: strings.TrimSuffix(date.Label, ":"), Date: formatTimestamp(date.Timestamp)})
+	}
+	return result
+}
+
+// prepareFolderTree makes the paths of all nodes relative to the html folder
+func prepareFolderTree(node *folderNode, modPath string) {
+	if node == nil {
+		return
+	}
+	node.Path = filepath.Join(modPath, node.Path)
+	for _, child := range node.Children {
+		prepareFolderTree(child, modPath)
+	}
+}
+
+// createBookPages creates one page per chapter, a page with all chapters merged and writes the first chapter into outputFile
+func createBookPages(book bookMod, mod courseModule, coursePath string, tmpl *template.Template, outputFile *os.File) error {
+	var chapters []*bookChapter
+	var flatten func(list []bookChapter, indent int)
+	flatten = func(list []bookChapter, indent int) {
+		for i := range list {
+			chapter := &list[i]
+			chapter.Indent = indent
+			chapter.Link = fmt.Sprintf("%d-%s.html", mod.PageID, chapter.ID)
+			chapter.HTML = template.HTML(prefixRelativeLinks(chapter.Content, "../"+mod.Path))
+			chapters = append(chapters, chapter)
+			flatten(chapter.SubChapters, indent+1)
+		}
+	}
+	flatten(book.Chapters, 0)
+
+	page := bookPage{Name: book.Name, Description: book.Description, Chapters: chapters, MergedLink: fmt.Sprintf("%d-all.html", mod.PageID)}
+	for i, chapter := range chapters {
+		page.Current = chapter
+		page.Prev = nil
+		page.Next = nil
+		if i > 0 {
+			page.Prev = chapters[i-1]
+		}
+		if i < len(chapters)-1 {
+			page.Next = chapters[i+1]
+		}
+		if i == 0 {
+			if err := tmpl.Execute(outputFile, page); err != nil {
+				return err
+			}
+		}
+		if err := executeTemplateToFile(tmpl, page, filepath.Join(coursePath, chapter.Link)); err != nil {
+			return err
+		}
+	}
+	if len(chapters) == 0 {
+		if err := tmpl.Execute(outputFile, page); err != nil {
+			return err
+		}
+	}
+	page.Current = nil
+	page.Prev = nil
+	page.Next = nil
+	page.Merged = true
+	return executeTemplateToFile(tmpl, page, filepath.Join(coursePath, page.MergedLink))
+}
+
+// createWikiPages creates one page per wiki page and writes the first page of the wiki into outputFile.
+// Links between the wiki pages are replaced with the generated pages
+func createWikiPages(wiki wikiMod, mod courseModule, coursePath string, tmpl *template.Template, outputFile *os.File) error {
+	pageLinks := map[int]string{}
+	titleLinks := map[string]string{}
+	for i := range wiki.Subwikis {
+		subwiki := &wiki.Subwikis[i]
+		if len(wiki.Subwikis) > 1 {
+			if subwiki.GroupID != 0 {
+				subwiki.Label = fmt.Sprintf("Group %d", subwiki.GroupID)
+			} else if subwiki.UserID != 0 {
+				subwiki.Label = fmt.Sprintf("User %d", subwiki.UserID)
+			}
+		}
+		for j := range subwiki.Pages {
+			page := &subwiki.Pages[j]
+			page.Link = fmt.Sprintf("%d-%d.html", mod.PageID, page.ID)
+			pageLinks[page.ID] = page.Link
+			titleLinks[fmt.Sprintf("%d/%s", subwiki.ID, page.Title)] = page.Link
+		}
+	}
+	prepareContent := func(content string) template.HTML {
+		content = prefixRelativeLinks(content, "../"+mod.Path)
+		content = rewriteWikiLinks(content, pageLinks, titleLinks)
+		return template.HTML(content)
+	}
+
+	var first *wikiPage
+	for i := range wiki.Subwikis {
+		for j := range wiki.Subwikis[i].Pages {
+			page := &wiki.Subwikis[i].Pages[j]
+			page.HTML = prepareContent(page.Content)
+			page.Date = formatTimestamp(page.TimeModified)
+			for k := range page.History {
+				page.History[k].HTML = prepareContent(page.History[k].Content)
+				page.History[k].Date = formatTimestamp(page.History[k].TimeModified)
+			}
+			if first == nil || (page.FirstPage && !first.FirstPage) {
+				first = page
+			}
+		}
+	}
+
+	view := wikiPageView{Name: wiki.Name, Description: wiki.Description, Subwikis: wiki.Subwikis}
+	for i := range wiki.Subwikis {
+		for j := range wiki.Subwikis[i].Pages {
+			view.Current = &wiki.Subwikis[i].Pages[j]
+			if err := executeTemplateToFile(tmpl, view, filepath.Join(coursePath, view.Current.Link)); err != nil {
+				return err
+			}
+		}
+	}
+	view.Current = first
+	return tmpl.Execute(outputFile, view)
+}
+
+// rewriteWikiLinks replaces the links to other pages of the wiki with the generated pages
+func rewriteWikiLinks(content string, pageLinks map[int]string, titleLinks map[string]string) string {
+	result, err := rewriteHtmlLinks(content, func(link string) string {
+		parsed, err := url.Parse(link)
+		if err != nil || !strings.HasSuffix(parsed.Path, "/mod/wiki/view.php") && !strings.HasSuffix(parsed.Path, "/mod/wiki/create.php") {
+			return link
+		}
+		query := parsed.Query()
+		if pageID, err := strconv.Atoi(query.Get("pageid")); err == nil {
+			if pageLink, ok := pageLinks[pageID]; ok {
+				return pageLink
+			}
+		}
+		if pageLink, ok := titleLinks[query.Get("swid")+"/"+query.Get("title")]; ok {
+			return pageLink
+		}
+		return link
+	})
+	if err != nil {
+		return content
+	}
+	return result
+}
+
+func executeTemplateToFile(tmpl *template.Template, data interface{}, outputPath string) error {
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+	return tmpl.Execute(outputFile, data)
+}
+
+// prepareGlossary groups the entries by their first letter and makes all links relative to the html folder
+func prepareGlossary(glossary *glossaryMod, modPath string) {
+	for i := range glossary.Entries {
+		entry := &glossary.Entries[i]
+		entry.DefinitionHTML = template.HTML(prefixRelativeLinks(entry.Definition, "../"+modPath))
+		entry.Date = formatTimestamp(entry.TimeModified)
+		entry.SearchText = strings.ToLower(strings.Join(append(append([]string{entry.Concept}, entry.Aliases...), entry.Categories...), " "))
+		for _, attachment := range entry.Attachments {
+			entry.AttachmentLinks = append(entry.AttachmentLinks, fileLink{Name: filepath.Base(attachment), Path: filepath.Join(modPath, attachment)})
+		}
+		letter := "#"
+		if runes := []rune(strings.TrimSpace(entry.Concept)); len(runes) > 0 && unicode.IsLetter(runes[0]) {
+			letter = strings.ToUpper(string(runes[0]))
+		}
+		if len(glossary.Letters) == 0 || glossary.Letters[len(glossary.Letters)-1].Letter != letter {
+			glossary.Letters = append(glossary.Letters, glossaryLetter{Letter: letter})
+		}
+		glossary.Letters[len(glossary.Letters)-1].Entries = append(glossary.Letters[len(glossary.Letters)-1].Entries, entry)
+	}
+}
+
+// prepareDiscussion builds the reply tree of the posts and makes all links relative to the html folder
+func prepareDiscussion(discussion *forumDiscussion, modPath string) {
+	discussion.Date = formatTimestamp(discussion.Created)
+	posts := map[int]*forumPost{}
+	for i := range discussion.Posts {
+		post := &discussion.Posts[i]
+		post.HTML = template.HTML(prefixRelativeLinks(post.Message, "../"+modPath))
+		post.Date = formatTimestamp(post.TimeCreated)
+		for _, attachment := range post.Attachments {
+			post.AttachmentLinks = append(post.AttachmentLinks, fileLink{Name: filepath.Base(attachment), Path: filepath.Join(modPath, attachment)})
+		}
+		posts[post.ID] = post
+	}
+	for i := range discussion.Posts {
+		post := &discussion.Posts[i]
+		if parent, ok := posts[post.ParentID]; ok && post.ParentID != post.ID {
+			parent.Replies = append(parent.Replies, post)
+		} else {
+			discussion.Thread = append(discussion.Thread, post)
+		}
+	}
+}
+
+func formatTimestamp(timestamp int64) string {
+	if timestamp == 0 {
+		return ""
+	}
+	return time.Unix(timestamp, 0).Format("02.01.2006 15:04")
+}
+
+// prepareVersions makes the paths of the versions relative to the html folder and formats the date
+func prepareVersions(versions []fileVersion, modPath string) {
+	for i := range versions {
+		versions[i].Path = filepath.Join(modPath, versions[i].Path)
+		versions[i].Date = formatTimestamp(versions[i].ArchivedAt)
+	}
+}
+
 func getCoursePage(moodlePath string) (coursesOverviewPage, error) {
 	entries, err := os.ReadDir(moodlePath)
 	if err != nil {
@@ -265,6 +1082,10 @@ func getCoursePage(moodlePath string) (coursesOverviewPage, error) {
 	var coursesPageData coursesOverviewPage
 	coursesPageData.Courses = []courseData{}
 	for _, entry := range entries {
+		// only the folders of courses are named by their id. E.g. the private files are not a course
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
 		if entry.IsDir() {
 			file := filepath.Join(moodlePath, entry.Name(), "data.json")
 			var data, err = os.ReadFile(file)
@@ -278,12 +1099,13 @@ func getCoursePage(moodlePath string) (coursesOverviewPage, error) {
 				logrus.Info("Could not parse Course data for Course ", entry.Name(), ". Skipping it")
 				continue
 			}
-			courseData.CourseImageURL = template.URL(courseData.CourseImage)
+			courseData.CourseImageURL = courseImageURL(courseData, "data")
 			sectionNameMap := make(map[int]string)
 			for _, section := range courseData.Sections {
 				sectionNameMap[section.ID] = section.Name
 			}
-			sections, err := getSections(filepath.Join(moodlePath, entry.Name()), sectionNameMap)
+			manifest := LoadCourseManifest(filepath.Join(moodlePath, entry.Name()), courseData.ID)
+			sections, err := getSections(filepath.Join(moodlePath, entry.Name()), sectionNameMap, manifest)
 			if err != nil {
 				logrus.Info("Could not retrieve sections. ", err)
 			}
@@ -295,7 +1117,21 @@ func getCoursePage(moodlePath string) (coursesOverviewPage, error) {
 	return coursesPageData, nil
 }
 
-func getSections(coursePath string, sectionNameMap map[int]string) ([]courseSection, error) {
+// attachmentPaths returns the paths of the attachments of an assignment. Older archives only have the names of the
+// files which were stored directly in dir
+func attachmentPaths(modPath string, dir string, names []string, files []string) []string {
+	var paths []string
+	for i, name := range names {
+		if len(files) == len(names) {
+			paths = append(paths, filepath.Join(modPath, files[i]))
+		} else {
+			paths = append(paths, filepath.Join(modPath, dir, name))
+		}
+	}
+	return paths
+}
+
+func getSections(coursePath string, sectionNameMap map[int]string, manifest *CourseManifest) ([]courseSection, error) {
 	entries, err := os.ReadDir(coursePath)
 	if err != nil {
 		return []courseSection{}, err
@@ -306,18 +1142,22 @@ func getSections(coursePath string, sectionNameMap map[int]string) ([]courseSect
 		if !entry.IsDir() {
 			continue
 		}
-		var sec, err = getSection(filepath.Join(coursePath, entry.Name()))
+		// the course folder also contains folders which are no sections, e.g. the images of the summary
+		num, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		sec, err := getSection(filepath.Join(coursePath, entry.Name()), manifest)
 		if err != nil {
 			logrus.Info("Could not get section ", entry.Name(), ". Skipping it")
 			continue
 		}
-		num, _ := strconv.Atoi(sec.Name)
 		sec.Name = sectionNameMap[num]
 		sections = append(sections, sec)
 	}
 	return sections, nil
 }
-func getSection(sectionPath string) (courseSection, error) {
+func getSection(sectionPath string, manifest *CourseManifest) (courseSection, error) {
 	entries, err := os.ReadDir(sectionPath)
 	if err != nil {
 		return courseSection{}, err
@@ -347,8 +1187,17 @@ func getSection(sectionPath string) (courseSection, error) {
 		mod.Name = result["name"].(string)
 		mod.ModName = result["modname"].(string)
 		mod.ID = int(result["id"].(float64))
+		mod.PageID = mod.ID
 		mod.Path = strings.Replace(filepath.Join(sectionPath, entry.Name()), moodlePath, "data", 1000)
 		mod.Data = result
+		if cmid, ok := result["cmid"].(float64); ok {
+			mod.Removed = manifest.IsRemoved(int(cmid))
+			mod.PageID = int(cmid)
+		}
+		mod.Dates = getModuleDates(result)
+		if status, ok := result["submissionstatus"].(string); ok {
+			mod.SubmissionStatus = status
+		}
 		section.CourseModules = append(section.CourseModules, mod)
 	}
 
@@ -0,0 +1,33 @@
+package moodle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetSectionsSkipsOtherFolders(t *testing.T) {
+	coursePath := t.TempDir()
+	modulePath := filepath.Join(coursePath, "5", "10")
+	if err := os.MkdirAll(modulePath, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	data := `{"id": 3, "cmid": 10, "name": "Notes", "modname": "label"}`
+	if err := os.WriteFile(filepath.Join(modulePath, "data.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(coursePath, "summary", "12"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	manifest := &CourseManifest{Modules: map[int]*ModuleManifest{}}
+	sections, err := getSections(coursePath, map[int]string{5: "Week 1"}, manifest)
+	if err != nil {
+		t.Fatalf("getSections() error = %v", err)
+	}
+	if len(sections) != 1 {
+		t.Fatalf("getSections() returned %d sections, want 1", len(sections))
+	}
+	if sections[0].Name != "Week 1" || len(sections[0].CourseModules) != 1 || sections[0].CourseModules[0].PageID != 10 {
+		t.Errorf("getSections() = %+v", sections[0])
+	}
+}
@@ -0,0 +1,72 @@
+package moodle
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"scar/progress"
+	"scar/util"
+)
+
+type DownloadPageData struct {
+	ID          int    `json:"id"`
+	CMID        int    `json:"cmid"`
+	Name        string `json:"name"`
+	ModName     string `json:"modname"`
+	Description string `json:"description"`
+	// Content is the html of the page. All links to files of the page are relative to the module folder
+	Content string `json:"content"`
+}
+
+func (courseApi *CourseApi) downloadPageModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
+	modulePath := fmt.Sprintf("%s/%d", basePath, moduleFolderID(module))
+
+	var indexPath string
+	files := map[string]string{}
+	for _, content := range module.Contents {
+		if content.Type != "file" {
+			continue
+		}
+		relPath := contentRelPath(content)
+		err := courseApi.downloadModuleFile(content.moodleFile(), modulePath, relPath, manifest, reporter)
+		if err != nil {
+			return err
+		}
+		if content.FileName == "index.html" && content.FilePath == "/" {
+			indexPath = relPath
+			continue
+		}
+		files[fileAreaPath(content.FilePath, content.FileName)] = relPath
+	}
+	if indexPath == "" {
+		return fmt.Errorf("page %s has no index.html", module.Name)
+	}
+
+	index, err := os.ReadFile(path.Join(modulePath, indexPath))
+	if err != nil {
+		return err
+	}
+	content, err := rewriteHtmlLinks(string(index), func(link string) string {
+		if !isPluginFileLink(link) {
+			return link
+		}
+		if localPath, ok := matchPluginFile(link, files); ok {
+			return localLink(localPath)
+		}
+		reporter.Warning(fmt.Sprintf("Could not find the file of %s in page %s", link, module.Name))
+		return link
+	})
+	if err != nil {
+		return err
+	}
+
+	var data DownloadPageData
+	data.ID = module.ID
+	data.CMID = module.ComponentID
+	data.Name = module.Name
+	data.ModName = module.ModName
+	data.Description = module.Description
+	data.Content = content
+
+	return util.SaveStructToJSON(data, modulePath+"/data.json")
+}
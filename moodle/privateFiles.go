@@ -0,0 +1,118 @@
+package moodle
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"scar/progress"
+	"scar/util"
+	"strconv"
+)
+
+// privateFilesItemID is the id of the item which downloads the private files of the user instead of a course
+const privateFilesItemID = "private"
+const privateFolderName = "private"
+
+// privateFilesDir is the folder inside the private folder which contains the files
+const privateFilesDir = "files"
+
+type PrivateFilesResponse struct {
+	Files []PrivateFile `json:"files"`
+	Error string        `json:"error"`
+}
+
+type PrivateFile struct {
+	FilePath     string `json:"filepath"`
+	FileName     string `json:"filename"`
+	IsDir        bool   `json:"isdir"`
+	URL          string `json:"url"`
+	FileSize     int64  `json:"filesize"`
+	TimeModified int64  `json:"timemodified"`
+}
+
+type DownloadPrivateFilesData struct {
+	Name string      `json:"name"`
+	Tree *FolderNode `json:"tree"`
+}
+
+// getPrivateFiles returns the files and directories inside filePath of the private files
+func (courseApi *CourseApi) getPrivateFiles(userID int, filePath string) ([]PrivateFile, error) {
+	body, err := courseApi.client.makeWebserviceRequest("core_files_get_files", map[string]string{
+		"contextid":    "0",
+		"contextlevel": "user",
+		"instanceid":   strconv.Itoa(userID),
+		"component":    "user",
+		"filearea":     "private",
+		"itemid":       "0",
+		"filepath":     filePath,
+		"filename":     "",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response PrivateFilesResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%v", response.Error)
+	}
+	return response.Files, nil
+}
+
+// listPrivateFiles walks the directories of the private files and returns all files
+func (courseApi *CourseApi) listPrivateFiles(userID int, filePath string) ([]PrivateFile, error) {
+	entries, err := courseApi.getPrivateFiles(userID, filePath)
+	if err != nil {
+		return nil, err
+	}
+	var files []PrivateFile
+	for _, entry := range entries {
+		if !entry.IsDir {
+			files = append(files, entry)
+			continue
+		}
+		if entry.FilePath == filePath {
+			continue
+		}
+		children, err := courseApi.listPrivateFiles(userID, entry.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, children...)
+	}
+	return files, nil
+}
+
+// DownloadPrivateFiles mirrors the private files of the user into basePath/private/files
+func (courseApi *CourseApi) DownloadPrivateFiles(basePath string, reporter progress.Reporter) error {
+	userID, err := courseApi.client.getUserID()
+	if err != nil {
+		return err
+	}
+	files, err := courseApi.listPrivateFiles(userID, "/")
+	if err != nil {
+		return err
+	}
+	privatePath := filepath.Join(basePath, privateFolderName)
+	reporter.Started("Private files", len(files))
+	var contents []CourseContent
+	for _, file := range files {
+		content := CourseContent{Type: "file", FileName: file.FileName, FilePath: file.FilePath, FileSize: file.FileSize}
+		relPath := fileTreeRelPath(privateFilesDir, content)
+		err := courseApi.client.downloadFile(webserviceFileURL(file.URL), filepath.Join(privatePath, relPath), file.FileSize, reporter)
+		if err != nil {
+			reporter.Failed(file.FileName, err)
+			continue
+		}
+		contents = append(contents, content)
+		reporter.ItemDone(file.FileName)
+	}
+
+	data := DownloadPrivateFilesData{Name: "Private files", Tree: buildFileTree(privateFilesDir, contents)}
+	if err := util.SaveStructToJSON(data, filepath.Join(privatePath, "data.json")); err != nil {
+		return err
+	}
+	reporter.Finished("Private files")
+	return nil
+}
@@ -0,0 +1,177 @@
+package moodle
+
+import (
+	"encoding/json"
+	"fmt"
+	"scar/progress"
+	"scar/util"
+	"strconv"
+)
+
+type QuizAttemptsResponse struct {
+	Attempts []QuizAttempt `json:"attempts"`
+	Error    string        `json:"error"`
+}
+
+type QuizAttempt struct {
+	ID         int      `json:"id"`
+	Attempt    int      `json:"attempt"`
+	State      string   `json:"state"`
+	TimeStart  int64    `json:"timestart"`
+	TimeFinish int64    `json:"timefinish"`
+	SumGrades  *float64 `json:"sumgrades"`
+}
+
+type QuizAttemptReviewResponse struct {
+	Grade          string `json:"grade"`
+	AdditionalData []struct {
+		ID      string `json:"id"`
+		Title   string `json:"title"`
+		Content string `json:"content"`
+	} `json:"additionaldata"`
+	Questions []QuizQuestion `json:"questions"`
+	Error     string         `json:"error"`
+}
+
+type QuizQuestion struct {
+	Slot    int     `json:"slot"`
+	Type    string  `json:"type"`
+	Page    int     `json:"page"`
+	HTML    string  `json:"html"`
+	Number  int     `json:"number"`
+	State   string  `json:"state"`
+	Status  string  `json:"status"`
+	Mark    string  `json:"mark"`
+	MaxMark float64 `json:"maxmark"`
+}
+
+type DownloadQuizData struct {
+	ID          int                   `json:"id"`
+	CMID        int                   `json:"cmid"`
+	Name        string                `json:"name"`
+	ModName     string                `json:"modname"`
+	Description string                `json:"description"`
+	Attempts    []DownloadQuizAttempt `json:"attempts"`
+}
+
+type DownloadQuizAttempt struct {
+	ID         int                    `json:"id"`
+	Attempt    int                    `json:"attempt"`
+	State      string                 `json:"state"`
+	TimeStart  int64                  `json:"timestart"`
+	TimeFinish int64                  `json:"timefinish"`
+	SumGrades  *float64               `json:"sumgrades"`
+	Grade      string                 `json:"grade"`
+	Feedback   []DownloadQuizFeedback `json:"feedback"`
+	Questions  []DownloadQuizQuestion `json:"questions"`
+}
+
+type DownloadQuizFeedback struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+type DownloadQuizQuestion struct {
+	Slot    int     `json:"slot"`
+	Number  int     `json:"number"`
+	Type    string  `json:"type"`
+	State   string  `json:"state"`
+	Status  string  `json:"status"`
+	Mark    string  `json:"mark"`
+	MaxMark float64 `json:"maxmark"`
+	// HTML contains the question, the answers and the feedback. Links are relative to the module folder
+	HTML string `json:"html"`
+}
+
+func (courseApi *CourseApi) getQuizAttempts(quizID int) ([]QuizAttempt, error) {
+	body, err := courseApi.client.makeWebserviceRequest("mod_quiz_get_user_attempts", map[string]string{
+		"quizid": strconv.Itoa(quizID),
+		"status": "all",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response QuizAttemptsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%v", response.Error)
+	}
+	return response.Attempts, nil
+}
+
+func (courseApi *CourseApi) getQuizAttemptReview(attemptID int) (*QuizAttemptReviewResponse, error) {
+	body, err := courseApi.client.makeWebserviceRequest("mod_quiz_get_attempt_review", map[string]string{
+		"attemptid": strconv.Itoa(attemptID),
+		"page":      "-1",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response QuizAttemptReviewResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%v", response.Error)
+	}
+	return &response, nil
+}
+
+func (courseApi *CourseApi) downloadQuizModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
+	modulePath := fmt.Sprintf("%s/%d", basePath, moduleFolderID(module))
+	attempts, err := courseApi.getQuizAttempts(module.ID)
+	if err != nil {
+		return err
+	}
+
+	var data DownloadQuizData
+	data.ID = module.ID
+	data.CMID = module.ComponentID
+	data.Name = module.Name
+	data.ModName = module.ModName
+	data.Description = courseApi.localizePluginFiles(module.Description, modulePath, "inline", manifest, reporter)
+
+	for _, attempt := range attempts {
+		downloadAttempt := DownloadQuizAttempt{
+			ID:         attempt.ID,
+			Attempt:    attempt.Attempt,
+			State:      attempt.State,
+			TimeStart:  attempt.TimeStart,
+			TimeFinish: attempt.TimeFinish,
+			SumGrades:  attempt.SumGrades,
+		}
+		// the review is only available for finished attempts
+		if attempt.State == "finished" {
+			review, err := courseApi.getQuizAttemptReview(attempt.ID)
+			if err != nil {
+				reporter.Warning(fmt.Sprintf("Could not get the review of attempt %d of %s: %s", attempt.Attempt, module.Name, err))
+			} else {
+				filesDir := fmt.Sprintf("attempts/%d", attempt.ID)
+				downloadAttempt.Grade = review.Grade
+				for _, additionalData := range review.AdditionalData {
+					downloadAttempt.Feedback = append(downloadAttempt.Feedback, DownloadQuizFeedback{
+						Title:   additionalData.Title,
+						Content: courseApi.localizePluginFiles(additionalData.Content, modulePath, filesDir, manifest, reporter),
+					})
+				}
+				for _, question := range review.Questions {
+					downloadAttempt.Questions = append(downloadAttempt.Questions, DownloadQuizQuestion{
+						Slot:    question.Slot,
+						Number:  question.Number,
+						Type:    question.Type,
+						State:   question.State,
+						Status:  question.Status,
+						Mark:    question.Mark,
+						MaxMark: question.MaxMark,
+						HTML:    courseApi.localizePluginFiles(question.HTML, modulePath, filesDir, manifest, reporter),
+					})
+				}
+			}
+		}
+		data.Attempts = append(data.Attempts, downloadAttempt)
+	}
+
+	return util.SaveStructToJSON(data, modulePath+"/data.json")
+}
@@ -0,0 +1,76 @@
+package moodle
+
+/**
+When versioning is enabled (config key moodle_keep_versions) a file which changed on moodle does not get overwritten.
+The old file is moved into <module>/.versions/<timestamp>/ and recorded in the manifest and the data.json of the module.
+*/
+import (
+	"os"
+	"path/filepath"
+	"scar/util"
+	"time"
+)
+
+const versionsFolderName = ".versions"
+
+// FileVersion is an old version of a file of a module. Path is relative to the module folder
+type FileVersion struct {
+	File         string `json:"file"`
+	Path         string `json:"path"`
+	Size         int64  `json:"size"`
+	TimeModified int64  `json:"timemodified"`
+	Hash         string `json:"hash"`
+	ArchivedAt   int64  `json:"archivedat"`
+}
+
+func keepVersions() bool {
+	return util.Config.GetBool("moodle_keep_versions", false)
+}
+
+// archiveFile moves the current file at modulePath/relPath into the versions folder.
+// The returned version is nil if there was no file to archive
+func (mm *ModuleManifest) archiveFile(modulePath string, relPath string) (*FileVersion, error) {
+	path := filepath.Join(modulePath, relPath)
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, nil
+	}
+	hash, err := hashFile(path)
+	if err != nil {
+		return nil, err
+	}
+	version := &FileVersion{File: relPath, Size: fileInfo.Size(), Hash: hash, ArchivedAt: time.Now().Unix()}
+	mm.mu.Lock()
+	if fm, ok := mm.Files[relPath]; ok {
+		version.TimeModified = fm.TimeModified
+	}
+	mm.mu.Unlock()
+	version.Path = filepath.Join(versionsFolderName, time.Now().Format("20060102-150405"), relPath)
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(modulePath, version.Path)), os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(path, filepath.Join(modulePath, version.Path)); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// keepVersion records the archived version if the new file differs from it. Otherwise the archived copy is removed again
+func (mm *ModuleManifest) keepVersion(modulePath string, version *FileVersion, newHash string) {
+	if version.Hash == newHash {
+		_ = os.Remove(filepath.Join(modulePath, version.Path))
+		_ = os.Remove(filepath.Dir(filepath.Join(modulePath, version.Path)))
+		return
+	}
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.Versions = append(mm.Versions, *version)
+}
+
+// saveVersionsInModuleData adds all known versions of the module to its data.json
+func saveVersionsInModuleData(modulePath string, versions []FileVersion) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	return saveInModuleData(modulePath, "versions", versions)
+}
@@ -0,0 +1,63 @@
+package moodle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveFile(t *testing.T) {
+	modulePath := t.TempDir()
+	path := filepath.Join(modulePath, "contents", "a.pdf")
+	writeTestFile(t, path, "old")
+	manifest := &ModuleManifest{Files: map[string]*FileManifest{"contents/a.pdf": {Size: 3, TimeModified: 100}}}
+
+	version, err := manifest.archiveFile(modulePath, "contents/a.pdf")
+	if err != nil || version == nil {
+		t.Fatalf("archiveFile() = %v, %v", version, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the old file is still at its path")
+	}
+	data, err := os.ReadFile(filepath.Join(modulePath, version.Path))
+	if err != nil || string(data) != "old" {
+		t.Errorf("archived file = %q, %v", data, err)
+	}
+	if version.File != "contents/a.pdf" || version.Size != 3 || version.TimeModified != 100 || version.Hash == "" {
+		t.Errorf("archiveFile() version = %+v", version)
+	}
+
+	missing, err := manifest.archiveFile(modulePath, "contents/b.pdf")
+	if missing != nil || err != nil {
+		t.Errorf("archiveFile() of a missing file = %v, %v, want nil, nil", missing, err)
+	}
+}
+
+func TestKeepVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		newHash string
+		kept    bool
+	}{
+		{"changed content", "new", true},
+		{"same content", "old", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			modulePath := t.TempDir()
+			version := &FileVersion{File: "a.pdf", Path: filepath.Join(versionsFolderName, "20240101-120000", "a.pdf"), Hash: "old"}
+			writeTestFile(t, filepath.Join(modulePath, version.Path), "old")
+			manifest := &ModuleManifest{Files: map[string]*FileManifest{}}
+
+			manifest.keepVersion(modulePath, version, test.newHash)
+
+			if kept := len(manifest.Versions) == 1; kept != test.kept {
+				t.Errorf("version recorded = %v, want %v", kept, test.kept)
+			}
+			_, err := os.Stat(filepath.Join(modulePath, version.Path))
+			if exists := err == nil; exists != test.kept {
+				t.Errorf("archived file exists = %v, want %v", exists, test.kept)
+			}
+		})
+	}
+}
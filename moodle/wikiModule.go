@@ -0,0 +1,214 @@
+package moodle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"scar/progress"
+	"scar/util"
+	"strconv"
+	"time"
+)
+
+type WikiSubwikisResponse struct {
+	Subwikis []WikiSubwiki `json:"subwikis"`
+	Error    string        `json:"error"`
+}
+
+type WikiSubwiki struct {
+	ID      int `json:"id"`
+	WikiID  int `json:"wikiid"`
+	GroupID int `json:"groupid"`
+	UserID  int `json:"userid"`
+}
+
+type WikiPagesResponse struct {
+	Pages []WikiPage `json:"pages"`
+	Error string     `json:"error"`
+}
+
+type WikiPage struct {
+	ID           int    `json:"id"`
+	SubwikiID    int    `json:"subwikiid"`
+	Title        string `json:"title"`
+	TimeCreated  int64  `json:"timecreated"`
+	TimeModified int64  `json:"timemodified"`
+	FirstPage    bool   `json:"firstpage"`
+}
+
+type WikiPageContentsResponse struct {
+	Page struct {
+		ID            int    `json:"id"`
+		Title         string `json:"title"`
+		CachedContent string `json:"cachedcontent"`
+		Version       int    `json:"version"`
+	} `json:"page"`
+	Error string `json:"error"`
+}
+
+type DownloadWikiData struct {
+	ID          int                   `json:"id"`
+	CMID        int                   `json:"cmid"`
+	Name        string                `json:"name"`
+	ModName     string                `json:"modname"`
+	Description string                `json:"description"`
+	Subwikis    []DownloadWikiSubwiki `json:"subwikis"`
+}
+
+type DownloadWikiSubwiki struct {
+	ID      int                `json:"id"`
+	GroupID int                `json:"groupid"`
+	UserID  int                `json:"userid"`
+	Pages   []DownloadWikiPage `json:"pages"`
+}
+
+type DownloadWikiPage struct {
+	ID           int    `json:"id"`
+	Title        string `json:"title"`
+	FirstPage    bool   `json:"firstpage"`
+	TimeCreated  int64  `json:"timecreated"`
+	TimeModified int64  `json:"timemodified"`
+	Version      int    `json:"version"`
+	// Content is the rendered html of the page. Links to images are relative to the module folder
+	Content string `json:"content"`
+	// History contains the older versions of the page which were seen at previous syncs
+	History []WikiPageVersion `json:"history"`
+}
+
+type WikiPageVersion struct {
+	Version      int    `json:"version"`
+	TimeModified int64  `json:"timemodified"`
+	Content      string `json:"content"`
+	ArchivedAt   int64  `json:"archivedat"`
+}
+
+func (courseApi *CourseApi) getWikiSubwikis(wikiID int) ([]WikiSubwiki, error) {
+	body, err := courseApi.client.makeWebserviceRequest("mod_wiki_get_subwikis", map[string]string{
+		"wikiid": strconv.Itoa(wikiID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response WikiSubwikisResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%v", response.Error)
+	}
+	return response.Subwikis, nil
+}
+
+func (courseApi *CourseApi) getWikiPages(wikiID int, subwiki WikiSubwiki) ([]WikiPage, error) {
+	body, err := courseApi.client.makeWebserviceRequest("mod_wiki_get_subwiki_pages", map[string]string{
+		"wikiid":                  strconv.Itoa(wikiID),
+		"groupid":                 strconv.Itoa(subwiki.GroupID),
+		"userid":                  strconv.Itoa(subwiki.UserID),
+		"options[sortby]":         "title",
+		"options[sortdirection]":  "ASC",
+		"options[includecontent]": "0",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response WikiPagesResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%v", response.Error)
+	}
+	return response.Pages, nil
+}
+
+func (courseApi *CourseApi) getWikiPageContents(pageID int) (*WikiPageContentsResponse, error) {
+	body, err := courseApi.client.makeWebserviceRequest("mod_wiki_get_page_contents", map[string]string{
+		"pageid": strconv.Itoa(pageID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response WikiPageContentsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%v", response.Error)
+	}
+	return &response, nil
+}
+
+func (courseApi *CourseApi) downloadWikiModule(module *CourseModule, basePath string, manifest *ModuleManifest, reporter progress.Reporter) error {
+	modulePath := fmt.Sprintf("%s/%d", basePath, moduleFolderID(module))
+	subwikis, err := courseApi.getWikiSubwikis(module.ID)
+	if err != nil {
+		return err
+	}
+	previousPages := loadWikiPages(modulePath + "/data.json")
+
+	var data DownloadWikiData
+	data.ID = module.ID
+	data.CMID = module.ComponentID
+	data.Name = module.Name
+	data.ModName = module.ModName
+	data.Description = courseApi.localizePluginFiles(module.Description, modulePath, "inline", manifest, reporter)
+
+	for _, subwiki := range subwikis {
+		pages, err := courseApi.getWikiPages(module.ID, subwiki)
+		if err != nil {
+			return err
+		}
+		downloadSubwiki := DownloadWikiSubwiki{ID: subwiki.ID, GroupID: subwiki.GroupID, UserID: subwiki.UserID}
+		for _, page := range pages {
+			contents, err := courseApi.getWikiPageContents(page.ID)
+			if err != nil {
+				reporter.Warning(fmt.Sprintf("Could not get wiki page %s: %s", page.Title, err))
+				continue
+			}
+			downloadPage := DownloadWikiPage{
+				ID:           page.ID,
+				Title:        page.Title,
+				FirstPage:    page.FirstPage,
+				TimeCreated:  page.TimeCreated,
+				TimeModified: page.TimeModified,
+				Version:      contents.Page.Version,
+				Content:      courseApi.localizePluginFiles(contents.Page.CachedContent, modulePath, "files", manifest, reporter),
+			}
+			if previous, ok := previousPages[page.ID]; ok {
+				downloadPage.History = previous.History
+				if previous.Version != downloadPage.Version && previous.Content != "" {
+					downloadPage.History = append(downloadPage.History, WikiPageVersion{
+						Version:      previous.Version,
+						TimeModified: previous.TimeModified,
+						Content:      previous.Content,
+						ArchivedAt:   time.Now().Unix(),
+					})
+				}
+			}
+			downloadSubwiki.Pages = append(downloadSubwiki.Pages, downloadPage)
+		}
+		data.Subwikis = append(data.Subwikis, downloadSubwiki)
+	}
+
+	return util.SaveStructToJSON(data, modulePath+"/data.json")
+}
+
+// loadWikiPages reads the pages of a previous sync to keep their older versions.
+// Moodle does not return the history of a page over the webservice
+func loadWikiPages(dataPath string) map[int]DownloadWikiPage {
+	pages := map[int]DownloadWikiPage{}
+	content, err := os.ReadFile(dataPath)
+	if err != nil {
+		return pages
+	}
+	var data DownloadWikiData
+	if err := json.Unmarshal(content, &data); err != nil {
+		return pages
+	}
+	for _, subwiki := range data.Subwikis {
+		for _, page := range subwiki.Pages {
+			pages[page.ID] = page
+		}
+	}
+	return pages
+}
@@ -0,0 +1,37 @@
+package progress
+
+/**
+This package contains the reporter which the download code uses to tell a frontend (tui, cli, ...) what it is doing.
+The download code never knows how the progress is shown.
+*/
+
+// Reporter receives the events of one download. E.g. a course with its modules or a book with its pages.
+// The methods can be called from multiple goroutines.
+type Reporter interface {
+	// Started is called when a download or a new phase of it (e.g. converting) starts.
+	// total is the amount of parts which will be reported with ItemDone or 0 if it is not known
+	Started(name string, total int)
+	// ItemDone is called after one part (module, page, ...) was finished
+	ItemDone(name string)
+	// BytesTransferred is called after bytes were downloaded
+	BytesTransferred(bytes int64)
+	// Info is a message which is only interesting for the user
+	Info(message string)
+	// Warning is a problem which does not stop the download
+	Warning(message string)
+	// Failed is called when a part could not be downloaded. It counts as done
+	Failed(name string, err error)
+	// Finished is called when the download is complete
+	Finished(name string)
+}
+
+// Nop is a reporter which ignores every event
+type Nop struct{}
+
+func (Nop) Started(string, int)    {}
+func (Nop) ItemDone(string)        {}
+func (Nop) BytesTransferred(int64) {}
+func (Nop) Info(string)            {}
+func (Nop) Warning(string)         {}
+func (Nop) Failed(string, error)   {}
+func (Nop) Finished(string)        {}
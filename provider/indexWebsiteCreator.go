@@ -1,7 +1,7 @@
-package screen
+package provider
 
 /**
-This class creates the index site for the html. It just iterates to all added screens and then creates the index html. It also provides the css and imgs to the correct folder
+This class creates the index site for the html. It just iterates to all given providers and then creates the index html. It also provides the css and imgs to the correct folder
 */
 import (
 	"github.com/sirupsen/logrus"
@@ -23,7 +23,8 @@ type ProviderCard struct {
 	FolderName string
 }
 
-func createIndexHtml() error {
+// CreateIndexHtml creates the index page which links to the pages of all the given providers
+func CreateIndexHtml(providers []Provider) error {
 	var archiverPath = util.Config.GetString("save_path")
 
 	tmpl, err := template.ParseFiles("html/index.html")
@@ -31,11 +32,11 @@ func createIndexHtml() error {
 		log.Fatal("Error loading template: ", err)
 	}
 	var providerPage ProviderPage
-	for _, screen := range App.Screens {
+	for _, p := range providers {
 		var pc ProviderCard
-		pc.Name = screen.Name
-		pc.ImageName = screen.ImageName
-		pc.FolderName = screen.FolderName
+		pc.Name = p.Name()
+		pc.ImageName = p.ImageName()
+		pc.FolderName = p.FolderName()
 		providerPage.Provider = append(providerPage.Provider, pc)
 	}
 
@@ -0,0 +1,74 @@
+package provider
+
+/**
+This package contains the interface every content source (moodle, digi4school, ...) has to implement.
+The tui and every other frontend only talk to the sources through this interface.
+*/
+import "scar/progress"
+
+// Item is one downloadable unit of a provider. E.g. a moodle course or a digi4school book
+type Item struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// ConfigKey describes one config value a provider needs. E.g. the username or the password
+type ConfigKey struct {
+	Key   string
+	Label string
+	// Secret values like passwords are masked and not saved in the config file when they are entered in the tui
+	Secret bool
+}
+
+// DueDate is a deadline inside an item. E.g. an assignment of a moodle course
+type DueDate struct {
+	Item       string `json:"item"`
+	ItemName   string `json:"itemname"`
+	Name       string `json:"name"`
+	DueDate    int64  `json:"duedate"`
+	CutoffDate int64  `json:"cutoffdate"`
+	Status     string `json:"status"`
+}
+
+// DueDateLister is implemented by providers which know deadlines. The dates are read from the downloaded data,
+// so no login is needed
+type DueDateLister interface {
+	// DueDates returns the deadlines of the given items or of all downloaded items if no ids are given
+	DueDates(itemIDs []string) ([]DueDate, error)
+}
+
+// ParallelDownloader is implemented by providers which can download multiple items at the same time
+type ParallelDownloader interface {
+	// ParallelItems returns how many items can be downloaded at the same time
+	ParallelItems() int
+}
+
+// ParallelItems returns how many items of the provider can be downloaded at the same time. Providers which do not
+// implement ParallelDownloader download one item after the other
+func ParallelItems(p Provider) int {
+	if parallel, ok := p.(ParallelDownloader); ok && parallel.ParallelItems() > 1 {
+		return parallel.ParallelItems()
+	}
+	return 1
+}
+
+type Provider interface {
+	// Name is the display name of the provider
+	Name() string
+	// FolderName is the folder inside the save path and the html folder where the provider stores its data
+	FolderName() string
+	// ImageName is the name of the image in html/imgs which is shown on the index page
+	ImageName() string
+	// ConfigKeys returns all config keys the provider reads. Mostly used to ask the user for credentials
+	ConfigKeys() []ConfigKey
+	// Login logs in with the credentials from the config
+	Login() error
+	Logout() error
+	// ListItems returns all items which can be downloaded
+	ListItems() ([]Item, error)
+	// DownloadItem downloads one item into the save path and reports what it does to the reporter
+	DownloadItem(item Item, reporter progress.Reporter) error
+	// CreateHtml creates the html pages for the downloaded data
+	CreateHtml() error
+}
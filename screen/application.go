@@ -1,12 +1,14 @@
 package screen
 
+import "scar/provider"
+
 var App *ScreenManager
 
 func CreateApplication() {
 	App = NewScreenManager()
 }
-func AddScreen(screen *Screen) {
-	App.AddScreen(screen)
+func AddProvider(p provider.Provider) {
+	App.AddProvider(p)
 }
 func RunApplication() {
 	App.BuildMainScreen()
@@ -0,0 +1,203 @@
+package screen
+
+/**
+The item picker is a checkbox list of the items of a provider. The list can be filtered by typing into the search field.
+The selection is saved in the config (<provider folder>_selected_items) and restored the next time.
+*/
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/sirupsen/logrus"
+	"scar/provider"
+	"scar/util"
+	"strings"
+)
+
+const pickerHelp = "Space/Enter: toggle  Ctrl-A: select all  Ctrl-N: select none  Ctrl-D: download selected  Tab/Shift-Tab: switch focus  Esc: back"
+
+type itemPicker struct {
+	sm       *ScreenManager
+	p        provider.Provider
+	items    []provider.Item
+	selected map[string]bool
+	visible  []provider.Item
+	search   *tview.InputField
+	list     *tview.List
+	layout   *tview.Flex
+}
+
+func selectionConfigKey(p provider.Provider) string {
+	return p.FolderName() + "_selected_items"
+}
+
+func (sm *ScreenManager) getItemList(p provider.Provider) tview.Primitive {
+	items, err := p.ListItems()
+	if err != nil {
+		logrus.Error("Could not list items of ", p.Name(), ": ", err)
+	}
+	picker := &itemPicker{sm: sm, p: p, items: items, selected: map[string]bool{}}
+	for _, id := range util.Config.GetStringList(selectionConfigKey(p), []string{}) {
+		picker.selected[id] = true
+	}
+
+	picker.search = tview.NewInputField().SetLabel("Search: ")
+	picker.search.SetChangedFunc(func(text string) {
+		picker.refresh()
+	})
+	picker.search.SetDoneFunc(func(key tcell.Key) {
+		sm.App.SetFocus(picker.list)
+	})
+
+	picker.list = tview.NewList().ShowSecondaryText(true)
+	picker.list.SetSelectedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
+		picker.toggle(index)
+	})
+	picker.list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyRune && event.Rune() == ' ' {
+			picker.toggle(picker.list.GetCurrentItem())
+			return nil
+		}
+		return event
+	})
+
+	buttons := tview.NewForm().
+		AddButton("Download selected", picker.downloadSelected).
+		AddButton("Select all", func() { picker.selectVisible(true) }).
+		AddButton("Select none", func() { picker.selectVisible(false) }).
+		AddButton("Back", picker.back)
+	help := tview.NewTextView().SetText(pickerHelp)
+
+	picker.layout = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(picker.search, 1, 0, true).
+		AddItem(picker.list, 0, 1, false).
+		AddItem(buttons, 3, 0, false).
+		AddItem(help, 1, 0, false)
+	picker.layout.SetBorder(true)
+	focusOrder := []tview.Primitive{picker.search, picker.list, buttons}
+	picker.layout.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyCtrlA:
+			picker.selectVisible(true)
+			return nil
+		case tcell.KeyCtrlN:
+			picker.selectVisible(false)
+			return nil
+		case tcell.KeyCtrlD:
+			picker.downloadSelected()
+			return nil
+		case tcell.KeyEscape:
+			picker.back()
+			return nil
+		case tcell.KeyTab, tcell.KeyBacktab:
+			forward := event.Key() == tcell.KeyTab
+			if buttons.HasFocus() {
+				// the form moves between its buttons itself. Only the first and the last button leave the form
+				_, button := buttons.GetFocusedItemIndex()
+				if button >= 0 && ((forward && button < buttons.GetButtonCount()-1) || (!forward && button > 0)) {
+					return event
+				}
+			}
+			for i, primitive := range focusOrder {
+				if !primitive.HasFocus() {
+					continue
+				}
+				next := (i + 1) % len(focusOrder)
+				if !forward {
+					next = (i + len(focusOrder) - 1) % len(focusOrder)
+				}
+				if focusOrder[next] == buttons {
+					// enter the buttons at the side the focus comes from
+					if forward {
+						buttons.SetFocus(0)
+					} else {
+						buttons.SetFocus(buttons.GetButtonCount() - 1)
+					}
+				}
+				sm.App.SetFocus(focusOrder[next])
+				return nil
+			}
+		}
+		return event
+	})
+	buttons.SetCancelFunc(picker.back)
+	picker.refresh()
+	return picker.layout
+}
+
+// refresh shows all items which match the search text
+func (picker *itemPicker) refresh() {
+	current := picker.list.GetCurrentItem()
+	search := strings.ToLower(picker.search.GetText())
+	picker.visible = nil
+	picker.list.Clear()
+	for _, item := range picker.items {
+		if search != "" && !strings.Contains(strings.ToLower(item.Name), search) && !strings.Contains(strings.ToLower(item.Description), search) {
+			continue
+		}
+		picker.visible = append(picker.visible, item)
+		mark := " "
+		if picker.selected[item.ID] {
+			mark = "x"
+		}
+		picker.list.AddItem(tview.Escape(fmt.Sprintf("[%s] %s", mark, item.Name)), tview.Escape(item.Description), 0, nil)
+	}
+	if len(picker.items) == 0 {
+		picker.list.AddItem("Nothing to download", "", 0, nil)
+	} else if len(picker.visible) == 0 {
+		picker.list.AddItem("Nothing found", "", 0, nil)
+	}
+	if current < picker.list.GetItemCount() {
+		picker.list.SetCurrentItem(current)
+	}
+	picker.layout.SetTitle(fmt.Sprintf("ScAr - %s (%d of %d selected)", picker.p.Name(), len(picker.selectedItems()), len(picker.items)))
+}
+
+func (picker *itemPicker) toggle(index int) {
+	if index < 0 || index >= len(picker.visible) {
+		return
+	}
+	id := picker.visible[index].ID
+	picker.selected[id] = !picker.selected[id]
+	picker.refresh()
+}
+
+// selectVisible selects or deselects all items which match the search
+func (picker *itemPicker) selectVisible(selected bool) {
+	for _, item := range picker.visible {
+		picker.selected[item.ID] = selected
+	}
+	picker.refresh()
+}
+
+// selectedItems returns the selected items in the order of the provider
+func (picker *itemPicker) selectedItems() []provider.Item {
+	var items []provider.Item
+	for _, item := range picker.items {
+		if picker.selected[item.ID] {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (picker *itemPicker) downloadSelected() {
+	items := picker.selectedItems()
+	if len(items) == 0 {
+		picker.sm.ShowPopup("Nothing is selected.", picker.layout, picker.layout)
+		return
+	}
+	ids := []string{}
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	util.Config.SaveValue(selectionConfigKey(picker.p), ids)
+	picker.sm.SwitchScreen(picker.sm.getProgressView(picker.p, items))
+}
+
+func (picker *itemPicker) back() {
+	if err := picker.p.Logout(); err != nil {
+		logrus.Error("Could not logout from ", picker.p.Name(), ": ", err)
+	}
+	picker.sm.SwitchToMainScreen()
+}
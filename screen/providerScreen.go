@@ -0,0 +1,203 @@
+package screen
+
+/**
+This file contains the generic screens for a provider. Login and the download progress. The item list is in itemPicker.go.
+*/
+import (
+	"fmt"
+	"github.com/rivo/tview"
+	"github.com/sirupsen/logrus"
+	"scar/provider"
+	"scar/util"
+	"strings"
+	"sync"
+)
+
+func (sm *ScreenManager) getLoginView(p provider.Provider) tview.Primitive {
+	var box = tview.NewBox()
+	box.SetFocusFunc(func() {
+		if p.Login() != nil {
+			sm.SwitchScreen(sm.getCredentialsView(p))
+		} else {
+			sm.SwitchScreen(sm.getItemList(p))
+		}
+	})
+	return box
+}
+
+func (sm *ScreenManager) getCredentialsView(p provider.Provider) tview.Primitive {
+	form := tview.NewForm()
+	for _, key := range p.ConfigKeys() {
+		if key.Secret {
+			form.AddPasswordField(key.Label+": ", util.Config.GetStringWD(key.Key, ""), 0, '*', nil)
+		} else {
+			form.AddInputField(key.Label+": ", util.Config.GetStringWD(key.Key, ""), 0, nil, nil)
+		}
+	}
+	form.AddButton("Login", func() {
+		for i, key := range p.ConfigKeys() {
+			value := form.GetFormItem(i).(*tview.InputField).GetText()
+			// secrets like passwords are only kept in memory and never written to the config file
+			if key.Secret {
+				util.Config.SetValue(key.Key, value)
+			} else {
+				util.Config.SaveValue(key.Key, value)
+			}
+		}
+		if err := p.Login(); err != nil {
+			logrus.Error("Could not login to ", p.Name(), ": ", err)
+			sm.ShowPopup("Wrong credentials. Please try again.", sm.getCredentialsView(p), sm.MainScreen)
+			return
+		}
+		sm.SwitchScreen(sm.getItemList(p))
+	})
+	form.AddButton("Cancel", func() {
+		sm.SwitchToMainScreen()
+	})
+	form.SetBorder(true).SetTitle(p.Name() + " - Credentials")
+
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(form, len(p.ConfigKeys())*2+5, 1, true).
+			AddItem(nil, 0, 1, false), 60, 1, true).
+		AddItem(nil, 0, 1, false)
+	return modal
+}
+
+func (sm *ScreenManager) getProgressView(p provider.Provider, items []provider.Item) tview.Primitive {
+	itemProgressBar := tview.NewTextView().SetScrollable(false)
+	logTextView := tview.NewTextView().
+		SetChangedFunc(func() {
+			sm.App.Draw()
+		})
+	logTextView.SetDynamicColors(true).
+		SetRegions(true).
+		SetWordWrap(true).
+		SetBorder(true).
+		SetTitle("Log Output")
+	itemProgressBar.SetText(progressText("Item", 0, len(items)))
+
+	// every worker shows the progress of its current item in its own bar
+	workers := provider.ParallelItems(p)
+	if workers > len(items) {
+		workers = len(items)
+	}
+	progressBars := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(itemProgressBar, 1, 0, false)
+	workerBars := make(chan *tview.TextView, workers)
+	for i := 0; i < workers; i++ {
+		partProgressBar := tview.NewTextView().SetScrollable(false)
+		progressBars.AddItem(partProgressBar, 1, 0, false)
+		workerBars <- partProgressBar
+	}
+
+	go func() {
+		var mu sync.Mutex
+		done := 0
+		_ = util.RunParallel(workers, len(items), func(index int) error {
+			item := items[index]
+			partProgressBar := <-workerBars
+			defer func() { workerBars <- partProgressBar }()
+			err := p.DownloadItem(item, &viewReporter{sm: sm, progressBar: partProgressBar, logView: logTextView})
+			if err != nil {
+				logrus.Errorf("Failed to download %s: %s", item.Name, err.Error())
+				fmt.Fprintf(logTextView, "Failed to download %s: %s\n", tview.Escape(item.Name), tview.Escape(err.Error()))
+			}
+			mu.Lock()
+			done++
+			text := progressText("Item", done, len(items))
+			mu.Unlock()
+			sm.App.QueueUpdateDraw(func() {
+				itemProgressBar.SetText(text)
+			})
+			return err
+		})
+		sm.App.QueueUpdateDraw(func() {
+			sm.SwitchScreen(sm.getItemList(p))
+		})
+	}()
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(progressBars, workers+1, 0, false).
+		AddItem(logTextView, 0, 1, true)
+	return flex
+}
+
+// progressText renders a progress bar. If the total is not known only the count is shown
+func progressText(label string, done int, total int) string {
+	if total <= 0 {
+		return fmt.Sprintf("%s [%d]", label, done)
+	}
+	progress := done * 100 / total
+	return fmt.Sprintf("%s [%d|%d]: [%-50s] %d%%", label, done, total, strings.Repeat("=", progress/2), progress)
+}
+
+// viewReporter shows the progress of a download in a progress bar and the log view
+type viewReporter struct {
+	sm          *ScreenManager
+	progressBar *tview.TextView
+	logView     *tview.TextView
+	mu          sync.Mutex
+	name        string
+	total       int
+	done        int
+	bytes       int64
+}
+
+func (r *viewReporter) update() {
+	r.mu.Lock()
+	text := progressText(r.name, r.done, r.total) + fmt.Sprintf(" (%.1f MB)", float64(r.bytes)/1024/1024)
+	r.mu.Unlock()
+	r.sm.App.QueueUpdateDraw(func() {
+		r.progressBar.SetText(text)
+	})
+}
+
+func (r *viewReporter) log(message string) {
+	fmt.Fprintln(r.logView, message)
+}
+
+func (r *viewReporter) Started(name string, total int) {
+	r.mu.Lock()
+	r.name = name
+	r.total = total
+	r.done = 0
+	r.mu.Unlock()
+	r.update()
+}
+
+func (r *viewReporter) ItemDone(name string) {
+	r.mu.Lock()
+	r.done++
+	r.mu.Unlock()
+	r.log("Downloaded: " + tview.Escape(name))
+	r.update()
+}
+
+func (r *viewReporter) BytesTransferred(bytes int64) {
+	r.mu.Lock()
+	r.bytes += bytes
+	r.mu.Unlock()
+	r.update()
+}
+
+func (r *viewReporter) Info(message string) {
+	r.log(tview.Escape(message))
+}
+
+func (r *viewReporter) Warning(message string) {
+	r.log("[yellow]Warning:[-] " + tview.Escape(message))
+}
+
+func (r *viewReporter) Failed(name string, err error) {
+	r.mu.Lock()
+	r.done++
+	r.mu.Unlock()
+	r.log("[red]Failed:[-] " + tview.Escape(fmt.Sprintf("%s: %s", name, err)))
+	r.update()
+}
+
+func (r *viewReporter) Finished(name string) {
+	r.log("Finished: " + tview.Escape(name))
+}
@@ -6,20 +6,13 @@ This class simply contains all the runtime screens and creates the ui for it.
 import (
 	"github.com/rivo/tview"
 	"github.com/sirupsen/logrus"
+	"scar/provider"
 )
 
 type ScreenManager struct {
 	App        *tview.Application
 	MainScreen tview.Primitive
-	Screens    []*Screen
-}
-
-type Screen struct {
-	Name         string
-	DownloadPage tview.Primitive
-	CreateHtml   func() error
-	ImageName    string
-	FolderName   string
+	Providers  []provider.Provider
 }
 
 func NewScreenManager() *ScreenManager {
@@ -27,17 +20,17 @@ func NewScreenManager() *ScreenManager {
 		App: tview.NewApplication(),
 	}
 }
-func (sm *ScreenManager) AddScreen(screen *Screen) {
-	sm.Screens = append(sm.Screens, screen)
+func (sm *ScreenManager) AddProvider(p provider.Provider) {
+	sm.Providers = append(sm.Providers, p)
 }
 
 func (sm *ScreenManager) BuildMainScreen() {
 	mainList := tview.NewList().
 		AddItem("Download", "Download content from a specific provider", '1', func() {
 			downloadList := tview.NewList()
-			for i, screen := range sm.Screens {
-				downloadList.AddItem(screen.Name, "", rune('1'+i), func() {
-					sm.App.SetRoot(screen.DownloadPage, true)
+			for i, p := range sm.Providers {
+				downloadList.AddItem(p.Name(), "", rune('1'+i), func() {
+					sm.SwitchScreen(sm.getLoginView(p))
 				})
 			}
 			downloadList.AddItem("Back", "", 'b', func() {
@@ -48,26 +41,26 @@ func (sm *ScreenManager) BuildMainScreen() {
 		}).AddItem("Create HTML", "Creates a html for a specific provider", '2', func() {
 		htmlList := tview.NewList()
 		htmlList.AddItem("All", "", '0', func() {
-			err := createIndexHtml()
+			err := provider.CreateIndexHtml(sm.Providers)
 			if err != nil {
 				logrus.Errorf("Could not create index html. Because: %s", err.Error())
 			}
-			for _, screen := range sm.Screens {
-				err = screen.CreateHtml()
+			for _, p := range sm.Providers {
+				err = p.CreateHtml()
 				if err != nil {
-					logrus.Errorf("Could not create html for %s. Because: %s", screen.Name, err.Error())
+					logrus.Errorf("Could not create html for %s. Because: %s", p.Name(), err.Error())
 				}
 			}
 		})
-		for i, screen := range sm.Screens {
-			htmlList.AddItem(screen.Name, "", rune('1'+i), func() {
-				err := createIndexHtml()
+		for i, p := range sm.Providers {
+			htmlList.AddItem(p.Name(), "", rune('1'+i), func() {
+				err := provider.CreateIndexHtml(sm.Providers)
 				if err != nil {
 					logrus.Errorf("Could not create index html. Because: %s", err.Error())
 				}
-				err = screen.CreateHtml()
+				err = p.CreateHtml()
 				if err != nil {
-					logrus.Errorf("Could not create html for %s. Because: %s", screen.Name, err.Error())
+					logrus.Errorf("Could not create html for %s. Because: %s", p.Name(), err.Error())
 				}
 			})
 		}
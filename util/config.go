@@ -7,10 +7,17 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 )
 
+// SimpleConfig is safe to use from multiple goroutines
 type SimpleConfig struct {
 	data map[string]interface{}
+	// memory contains values which are only kept while the program runs, e.g. passwords typed into the tui
+	memory map[string]interface{}
+	mu     sync.RWMutex
 }
 
 var Config SimpleConfig
@@ -29,9 +36,26 @@ func createDefaultConfig() map[string]interface{} {
 	data["moodle_url"] = ""
 	data["moodle_username"] = ""
 	data["moodle_password"] = ""
+	data["moodle_keep_versions"] = false
+	data["moodle_course_classification"] = "all"
+	data["moodle_course_include"] = ""
+	data["moodle_course_exclude"] = ""
+	data["moodle_course_categories"] = []string{}
+	data["moodle_course_excluded_categories"] = []string{}
+	data["moodle_course_ids"] = []string{}
+	data["moodle_course_excluded_ids"] = []string{}
+	data["moodle_course_workers"] = 2
+	data["moodle_module_workers"] = 4
+	data["moodle_file_workers"] = 4
+	data["moodle_requests_per_second"] = 10
 
 	data["digi4s_username"] = ""
 	data["digi4s_password"] = ""
+	data["digi4s_requests_per_second"] = 10
+
+	data["http_timeout_seconds"] = 60
+	data["http_max_retries"] = 3
+	data["http_user_agent"] = "Mozilla/5.0 (Windows NT 10.0; rv:129.0) Gecko/20100101 Firefox/129.0"
 	return data
 }
 
@@ -54,6 +78,8 @@ func getConfigFilePath() (string, error) {
 }
 
 func (sc *SimpleConfig) Load() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
 	configFile, err := getConfigFilePath()
 	if err != nil {
 		logrus.Fatal("Could not load config. ", err.Error())
@@ -77,7 +103,7 @@ func (sc *SimpleConfig) Load() {
 	sc.data = result
 }
 func (sc *SimpleConfig) GetString(key string) string {
-	if value, ok := sc.data[key]; ok {
+	if value, ok := sc.get(key); ok {
 		if str, ok := value.(string); ok {
 			return str
 		}
@@ -86,7 +112,7 @@ func (sc *SimpleConfig) GetString(key string) string {
 	return ""
 }
 func (sc *SimpleConfig) GetStringWD(key string, defaultValue string) string {
-	if value, ok := sc.data[key]; ok {
+	if value, ok := sc.get(key); ok {
 		if str, ok := value.(string); ok {
 			return str
 		}
@@ -96,7 +122,7 @@ func (sc *SimpleConfig) GetStringWD(key string, defaultValue string) string {
 	return defaultValue
 }
 func (sc *SimpleConfig) GetInt(key string, defaultValue int) int {
-	if value, ok := sc.data[key]; ok {
+	if value, ok := sc.get(key); ok {
 		if floatValue, ok := value.(float64); ok {
 			return int(floatValue)
 		}
@@ -105,8 +131,48 @@ func (sc *SimpleConfig) GetInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func (sc *SimpleConfig) GetBool(key string, defaultValue bool) bool {
+	if value, ok := sc.get(key); ok {
+		if boolValue, ok := value.(bool); ok {
+			return boolValue
+		}
+	}
+	sc.SaveValue(key, defaultValue)
+	return defaultValue
+}
+
+// GetStringList returns a list of strings. The value can be a json array or a comma separated string
+func (sc *SimpleConfig) GetStringList(key string, defaultValue []string) []string {
+	if value, ok := sc.get(key); ok {
+		switch listValue := value.(type) {
+		case []interface{}:
+			var list []string
+			for _, item := range listValue {
+				if str, ok := item.(string); ok {
+					list = append(list, str)
+				} else if number, ok := item.(float64); ok {
+					list = append(list, strconv.FormatFloat(number, 'f', -1, 64))
+				}
+			}
+			return list
+		case []string:
+			return listValue
+		case string:
+			var list []string
+			for _, item := range strings.Split(listValue, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			return list
+		}
+	}
+	sc.SaveValue(key, defaultValue)
+	return defaultValue
+}
+
 func (sc *SimpleConfig) GetFloat(key string, defaultValue float64) float64 {
-	if value, ok := sc.data[key]; ok {
+	if value, ok := sc.get(key); ok {
 		if floatValue, ok := value.(float64); ok {
 			return floatValue
 		}
@@ -115,10 +181,33 @@ func (sc *SimpleConfig) GetFloat(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 func (sc *SimpleConfig) SaveValue(key string, value interface{}) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
 	sc.data[key] = value
 	sc.save()
 }
 
+// SetValue sets the value only in memory. It overrides the value of the config file but is never written to it
+func (sc *SimpleConfig) SetValue(key string, value interface{}) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.memory == nil {
+		sc.memory = map[string]interface{}{}
+	}
+	sc.memory[key] = value
+}
+
+func (sc *SimpleConfig) get(key string) (interface{}, bool) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	if value, ok := sc.memory[key]; ok {
+		return value, true
+	}
+	value, ok := sc.data[key]
+	return value, ok
+}
+
+// save writes the config file. The caller must hold the lock
 func (sc *SimpleConfig) save() {
 	configFile, err := getConfigFilePath()
 	if err != nil {
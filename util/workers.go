@@ -0,0 +1,35 @@
+package util
+
+import (
+	"errors"
+	"sync"
+)
+
+// RunParallel calls task for every index from 0 to count-1 with at most workers goroutines at the same time.
+// It waits until all tasks are done and returns the errors of all failed tasks joined together
+func RunParallel(workers int, count int, task func(index int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > count {
+		workers = count
+	}
+	jobs := make(chan int)
+	errs := make([]error, count)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				errs[index] = task(index)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return errors.Join(errs...)
+}